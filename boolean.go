@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// BooleanLiterals represents the cell values which are parsed as booleans, e.g. "yes" and "no" or "○" and "×".
+// They are compared case-insensitively, and empty cells of boolean columns are false.
+type BooleanLiterals struct {
+	TrueValues  []string `yaml:"true_values" toml:"true_values"`
+	FalseValues []string `yaml:"false_values" toml:"false_values"`
+}
+
+var defaultBooleanLiterals = &BooleanLiterals{TrueValues: []string{"TRUE"}, FalseValues: []string{"FALSE"}}
+
+func (b *BooleanLiterals) validate() error {
+	if len(b.TrueValues) == 0 || len(b.FalseValues) == 0 {
+		return fmt.Errorf("Boolean literals should have true_values and false_values")
+	}
+	for _, value := range b.TrueValues {
+		if b.isFalse(value) {
+			return fmt.Errorf("Boolean literal is both true and false: %v", value)
+		}
+	}
+	return nil
+}
+
+// parse returns the boolean of the value, and whether the value is a boolean literal.
+func (b *BooleanLiterals) parse(value string) (bool, bool) {
+	if b.isTrue(value) {
+		return true, true
+	}
+	return false, b.isFalse(value)
+}
+
+// withoutNumbers returns the literals except numbers, e.g. "1" and "0", which are used only for the columns
+// given them, so that the literals of the project don't turn number columns into boolean columns.
+func (b *BooleanLiterals) withoutNumbers() *BooleanLiterals {
+	trueValues, falseValues := removeNumberLiterals(b.TrueValues), removeNumberLiterals(b.FalseValues)
+	if len(trueValues) == len(b.TrueValues) && len(falseValues) == len(b.FalseValues) {
+		return b
+	}
+	return &BooleanLiterals{TrueValues: trueValues, FalseValues: falseValues}
+}
+
+func (b *BooleanLiterals) isTrue(value string) bool {
+	return containsFold(b.TrueValues, value)
+}
+
+func (b *BooleanLiterals) isFalse(value string) bool {
+	return containsFold(b.FalseValues, value)
+}
+
+// removeNumberLiterals returns the values except numbers, or the values themselves if they have no numbers.
+func removeNumberLiterals(values []string) []string {
+	for i, value := range values {
+		if numberValuePattern.MatchString(value) {
+			result := append([]string{}, values[:i]...)
+			for _, value := range values[i+1:] {
+				if !numberValuePattern.MatchString(value) {
+					result = append(result, value)
+				}
+			}
+			return result
+		}
+	}
+	return values
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestBooleanLiterals(t *testing.T) {
+	Convey("BooleanLiterals", t, func() {
+		booleans := &BooleanLiterals{TrueValues: []string{"Yes", "○"}, FalseValues: []string{"No", "×"}}
+
+		Convey("#parse", func() {
+			Convey("should parse the literals case-insensitively", func() {
+				for value, expected := range map[string]bool{"yes": true, "YES": true, "○": true, "no": false, "×": false} {
+					actual, ok := booleans.parse(value)
+					So(ok, ShouldBeTrue)
+					So(actual, ShouldEqual, expected)
+				}
+				_, ok := booleans.parse("TRUE")
+				So(ok, ShouldBeFalse)
+			})
+		})
+
+		Convey("#withoutNumbers", func() {
+			Convey("should return the literals except numbers", func() {
+				numbers := &BooleanLiterals{TrueValues: []string{"TRUE", "1"}, FalseValues: []string{"0", "FALSE"}}
+				So(numbers.withoutNumbers(), ShouldResemble,
+					&BooleanLiterals{TrueValues: []string{"TRUE"}, FalseValues: []string{"FALSE"}})
+				So(booleans.withoutNumbers(), ShouldEqual, booleans)
+			})
+		})
+
+		Convey("#validate", func() {
+			Convey("should return error if a literal is both true and false", func() {
+				So(booleans.validate(), ShouldBeNil)
+				So((&BooleanLiterals{TrueValues: []string{"1"}, FalseValues: []string{"0", "1"}}).validate(), ShouldNotBeNil)
+				So((&BooleanLiterals{TrueValues: []string{"1"}}).validate(), ShouldNotBeNil)
+			})
+		})
+	})
+}
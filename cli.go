@@ -1,28 +1,51 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/ttacon/chalk"
 )
 
 // Cli represents the master command.
 type Cli struct {
-	dir            string
-	file           string
-	outputDir      string
-	schemaDir      string
-	encoding       string
-	fixEncoding    bool
-	noOutputFile   bool
-	outputSchema   bool
-	skipValidation bool
-	noSchemaSuffix bool
-	silent         bool
+	dir                string
+	file               string
+	outputDir          string
+	schemaDir          string
+	assetDir           string
+	encoding           string
+	fixEncoding        bool
+	location           *time.Location
+	datetimeFormat     DatetimeFormat
+	noOutputFile       bool
+	outputSchema       bool
+	schemaStrictness   SchemaStrictness
+	updateSchema       bool
+	skipValidation     bool
+	noSchemaSuffix     bool
+	outputTypeScript   bool
+	readonlyTypeScript bool
+	outputProto        bool
+	outputProtobuf     bool
+	variant            string
+	variants           []string
+	locales            []string
+	format             string
+	diffFormat         string
+	fix                bool
+	disabledLintRules  []string
+	booleanLiterals    *BooleanLiterals
+	stream             bool
+	fileConfigs        map[string]*FileConfig
+	silent             bool
 }
 
 func (c *Cli) run() {
@@ -32,25 +55,160 @@ func (c *Cli) run() {
 	if !c.noOutputFile {
 		c.makeOutputDirs()
 	}
+	if c.stream {
+		c.streamJSON()
+		return
+	}
 
 	for _, masterData := range c.masterDataList() {
-		if c.outputSchema {
-			jsonSchemaPath := filepath.Join(c.schemaDir,
-				strings.Replace(masterData.fileName, ".json", ".schema.json", 1))
-			c.writeFile("Generated", jsonSchemaPath, []byte(masterData.jsonSchema()))
+		var stringTables []*MasterData
+		if len(c.locales) > 0 {
+			stringTables = c.localize(masterData)
+		}
+
+		if c.outputSchema || c.updateSchema {
+			c.writeJSONSchema(masterData)
 		}
 
 		jsonText := masterData.json()
+		if c.format == "jsonl" {
+			var err error
+			if jsonText, err = masterData.jsonLines(); err != nil {
+				fatalf("Failed to convert master data to JSON Lines: %v\n%v", masterData.fileName, err)
+			}
+		}
 
 		if !c.skipValidation {
 			c.validateJSON(masterData.fileName, jsonText)
+			c.validateRules(masterData)
 		}
 		if !c.noOutputFile {
-			jsonPath := filepath.Join(c.outputDir, masterData.fileName)
-			c.writeFile("Generated", jsonPath, []byte(jsonText))
+			c.writeFile("Generated", c.outputPath(filepath.Join(c.outputDir, masterData.fileName)), []byte(jsonText))
+			for _, stringTable := range stringTables {
+				c.writeFile("Generated", filepath.Join(c.outputDir, stringTable.fileName), []byte(stringTable.json()))
+			}
+
+			if c.outputTypeScript {
+				typeScript, err := masterData.typeScript(c.readonlyTypeScript)
+				if err != nil {
+					fatalf("Failed to generate TypeScript type definitions: %v\n%v", masterData.fileName, err)
+				}
+				c.writeFile("Generated", c.tableOutputPath(masterData, ".d.ts"), []byte(typeScript))
+			}
+			if c.outputProto {
+				protoSchema, err := masterData.protoSchema(c.previousProtoSchema(masterData))
+				if err != nil {
+					fatalf("Failed to generate Protocol Buffers schema: %v\n%v", masterData.fileName, err)
+				}
+				c.writeFile("Generated", c.tableOutputPath(masterData, ".proto"), []byte(protoSchema))
+			}
+			if c.outputProtobuf {
+				protoBinary, err := masterData.protoBinary(c.previousProtoSchema(masterData))
+				if err != nil {
+					fatalf("Failed to serialize Protocol Buffers binary: %v\n%v", masterData.fileName, err)
+				}
+				c.writeFile("Generated", c.tableOutputPath(masterData, ".pb"), protoBinary)
+			}
 		} else if c.hasSingleCSVFile() {
-			c.log(jsonText)
+			c.log(strings.TrimSuffix(jsonText, "\n"))
+		}
+	}
+}
+
+// streamJSON converts the CSV files to JSON files record by record, which keeps the memory bounded for large files.
+// The data is not validated, and the outputs other than JSON and JSON Lines are not supported.
+func (c *Cli) streamJSON() {
+	if c.outputSchema || c.updateSchema || c.outputTypeScript || c.outputProto || c.outputProtobuf || len(c.locales) > 0 {
+		fatalf("Failed to parse arguments\nStreaming supports only JSON or JSON Lines output")
+	}
+	relationsPath := filepath.Join(c.schemaDir, "relations.yaml")
+	if _, err := os.Stat(relationsPath); err == nil {
+		fatalf("Failed to stream CSV data: %v\nChild tables cannot be joined in streaming", relationsPath)
+	}
+
+	options := c.csvTableOptions()
+	for _, filePath := range c.csvFilePaths() {
+		if isVariantCSVPath(filePath, c.variantNames()) {
+			continue
+		}
+		if c.variant != "" {
+			if _, err := os.Stat(variantCSVPath(filePath, c.variant)); err == nil {
+				fatalf("Failed to stream CSV data: %v\nVariant files cannot be overlaid in streaming", filePath)
+			}
+		}
+
+		fileConfig := c.fileConfig(filePath)
+		jsonPath := c.outputPath(filepath.Join(c.outputDir, strings.Replace(filepath.Base(filePath), ".csv", ".json", 1)))
+		var writer io.Writer = ioutil.Discard
+		var file *os.File
+		if !c.noOutputFile {
+			var err error
+			if file, err = os.Create(jsonPath + ".tmp"); err != nil {
+				fatalf("Failed to write a file\n%v", err)
+			}
+			writer = file
+		} else if c.hasSingleCSVFile() && !c.silent {
+			writer = os.Stdout
+		}
+
+		recordWriter := newRecordWriter(writer, c.format, strings.Repeat(" ", fileConfig.indent(2)))
+		err := streamCSV(filePath, c.csvFileOpener(filePath), fileConfig.csvTableOptions(options), recordWriter.write)
+		if err == nil {
+			err = recordWriter.close()
+		}
+		if err != nil {
+			if file != nil {
+				file.Close()
+				os.Remove(file.Name())
+			}
+			fatalf("Failed to stream CSV data: %v\n%v", filePath, err)
+		}
+
+		if file != nil {
+			if err := file.Close(); err != nil {
+				fatalf("Failed to write a file\n%v", err)
+			}
+			if err := os.Rename(file.Name(), jsonPath); err != nil {
+				fatalf("Failed to write a file\n%v", err)
+			}
+			c.log("Generated", chalk.Cyan.Color(jsonPath))
+		} else if writer == os.Stdout && c.format != "jsonl" {
+			fmt.Println()
+		}
+	}
+}
+
+// csvFileOpener returns the function which opens the CSV file as the decoded data.
+// The encoding is detected from the beginning of the file so as not to read the whole file.
+func (c *Cli) csvFileOpener(filePath string) func() (io.ReadCloser, error) {
+	encoding := c.encoding
+	if encoding == "auto" {
+		file, err := os.Open(filePath)
+		if err != nil {
+			fatalf("Failed to read a file: %v\n%v", filePath, err)
+		}
+		prefix, err := ioutil.ReadAll(io.LimitReader(file, encodingDetectionSize))
+		file.Close()
+		if err != nil {
+			fatalf("Failed to read a file: %v\n%v", filePath, err)
+		}
+		encoding = c.detectEncoding(filePath, prefix)
+	}
+
+	return func() (io.ReadCloser, error) {
+		file, err := os.Open(filePath)
+		if err != nil {
+			return nil, err
 		}
+		reader, err := newDecodeReader(file, encoding)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		return struct {
+			io.Reader
+			io.Closer
+		}{reader, file}, nil
 	}
 }
 
@@ -60,6 +218,39 @@ func (c *Cli) log(args ...interface{}) {
 	}
 }
 
+// localize removes the localized values from the master data, and returns the string tables of the locales.
+func (c *Cli) localize(masterData *MasterData) []*MasterData {
+	stringTables, missings, err := masterData.localize(c.locales)
+	if err != nil {
+		fatalf("Failed to split localized values: %v\n%v", masterData.fileName, err)
+	}
+	for _, missing := range missings {
+		c.log(chalk.Yellow.Color("[Warning]"), "Missing translation in "+masterData.fileName+":", missing)
+	}
+	return stringTables
+}
+
+func (c *Cli) writeJSONSchema(masterData *MasterData) {
+	jsonSchemaPath := filepath.Join(c.schemaDir,
+		strings.Replace(masterData.fileName, ".json", ".schema.json", 1))
+
+	if c.updateSchema {
+		if data, err := ioutil.ReadFile(jsonSchemaPath); err == nil {
+			merged, changes, err := masterData.mergedJSONSchema(string(data), c.schemaStrictness)
+			if err != nil {
+				fatalf("Failed to merge JSON Schema: %v\n%v", jsonSchemaPath, err)
+			}
+			c.writeFile("Updated", jsonSchemaPath, []byte(merged))
+			for _, change := range changes {
+				c.log("  " + change)
+			}
+			return
+		}
+	}
+	c.writeFile("Generated", jsonSchemaPath, []byte(masterData.jsonSchema(c.schemaStrictness)))
+}
+
+// validateJSON validates the JSON text, or each record of the JSON Lines text by the items subschema.
 func (c *Cli) validateJSON(fileName string, jsonText string) {
 	var schemaPath string
 	if c.noSchemaSuffix {
@@ -68,15 +259,59 @@ func (c *Cli) validateJSON(fileName string, jsonText string) {
 		schemaPath = filepath.Join(c.schemaDir, strings.Replace(fileName, ".json", ".schema.json", 1))
 	}
 
-	schemaData, err := ioutil.ReadFile(schemaPath)
-	if err == nil {
-		schemaText := string(schemaData)
-		if err := validateJSON(jsonText, schemaText); err != nil {
+	if _, err := os.Stat(schemaPath); err == nil {
+		validate := validateJSONWithSchemaFile
+		if c.format == "jsonl" {
+			validate = validateJSONLinesWithSchemaFile
+		}
+		if err := validate(jsonText, schemaPath, c.schemaDir, c.assetDir); err != nil {
 			fatalf("Failed to validate generated JSON: %v\n%v", fileName, err)
 		}
 	}
 }
 
+func (c *Cli) validateRules(masterData *MasterData) {
+	rulesPath := filepath.Join(c.schemaDir, strings.Replace(masterData.fileName, ".json", ".rules.yaml", 1))
+	data, err := ioutil.ReadFile(rulesPath)
+	if os.IsNotExist(err) {
+		return
+	} else if err != nil {
+		fatalf("Failed to read a file: %v\n%v", rulesPath, err)
+	}
+
+	rules, err := newRules(data)
+	if err != nil {
+		fatalf("Failed to parse rules: %v\n%v", rulesPath, err)
+	}
+	if err := rules.validate(masterData.records()); err != nil {
+		fatalf("Failed to validate generated JSON by rules: %v\n%v", masterData.fileName, err)
+	}
+}
+
+// tableOutputPath returns the path of the output file of the table with the extension, e.g. "items.d.ts".
+func (c *Cli) tableOutputPath(masterData *MasterData, extension string) string {
+	return filepath.Join(c.outputDir, masterData.tableName()+extension)
+}
+
+// previousProtoSchema returns the Protocol Buffers schema in the output directory, whose field numbers are kept.
+func (c *Cli) previousProtoSchema(masterData *MasterData) string {
+	data, err := ioutil.ReadFile(c.tableOutputPath(masterData, ".proto"))
+	if os.IsNotExist(err) {
+		return ""
+	} else if err != nil {
+		fatalf("Failed to read a file: %v\n%v", c.tableOutputPath(masterData, ".proto"), err)
+	}
+	return string(data)
+}
+
+// outputPath returns the path of the output file in the format, e.g. "items.jsonl" for "items.json".
+func (c *Cli) outputPath(jsonPath string) string {
+	if c.format == "jsonl" {
+		return jsonPath + "l"
+	}
+	return jsonPath
+}
+
 func (c *Cli) makeOutputDirs() {
 	if err := os.MkdirAll(c.outputDir, 0777); err != nil {
 		fatalf("Failed to make output directories\n%v", err)
@@ -138,32 +373,208 @@ func (c *Cli) fixCSVEncoding() {
 	}
 }
 
-func (c *Cli) masterDataList() []*MasterData {
-	filePaths := c.csvFilePaths()
-	result := make([]*MasterData, len(filePaths))
+func (c *Cli) exportCSV() {
+	c.makeOutputDirs()
+
+	encoding := c.encoding
+	if encoding == "auto" {
+		encoding = "UTF-8"
+	}
+
+	for _, filePath := range c.jsonFilePaths() {
+		csvData, err := exportCSV(c.readFile(filePath))
+		if err != nil {
+			fatalf("Failed to convert JSON data to CSV data: %v\n%v", filePath, err)
+		}
+		encoded, err := encode(csvData, encoding)
+		if err != nil {
+			fatalf("Failed to encode CSV data: %v\n%v", filePath, err)
+		}
+		csvPath := filepath.Join(c.outputDir, strings.Replace(filepath.Base(filePath), ".json", ".csv", 1))
+		c.writeFile("Exported", csvPath, encoded)
+	}
+}
+
+// diff outputs the changes of the records from the master data to the new master data.
+func (c *Cli) diff(newCli *Cli) {
+	tableDiffs, err := diffMasterDataList(c.masterDataList(), newCli.masterDataList())
+	if err != nil {
+		fatalf("Failed to compare master data\n%v", err)
+	}
 
-	for i, filePath := range filePaths {
+	if c.diffFormat == "json" {
+		if tableDiffs == nil {
+			tableDiffs = []*TableDiff{}
+		}
+		data, err := json.MarshalIndent(tableDiffs, "", "  ")
+		if err != nil {
+			fatalf("Failed to serialize diff\n%v", err)
+		}
+		c.log(string(data))
+	} else {
+		c.log(formatTableDiffs(tableDiffs))
+	}
+}
+
+// lint reports the problems of the CSV files, and fixes them in the original encoding with the fix option.
+// It fails if there are problems which are not fixed.
+func (c *Cli) lint() {
+	rules, err := enabledLintRules(c.disabledLintRules)
+	if err != nil {
+		fatalf("Failed to configure lint rules\n%v", err)
+	}
+	options := c.csvTableOptions()
+
+	problemCount := 0
+	for _, filePath := range c.csvFilePaths() {
 		data := c.readFile(filePath)
 		encoding := c.encoding
 		if encoding == "auto" {
 			encoding = c.detectEncoding(filePath, data)
 		}
 		decoded := c.decode(filePath, encoding, data)
-
-		csvTable, err := newCSVTable(filePath, encoding, decoded)
+		findings, fixed, err := lintCSV(decoded, rules, c.fileConfig(filePath).csvTableOptions(options))
 		if err != nil {
-			fatalf("Failed to parse CSV data: %v\n%v", filePath, err)
+			fatalf("Failed to lint CSV data: %v\n%v", filePath, err)
 		}
 
-		masterData, err := newMasterDataFromCSV(csvTable, 2)
-		if err != nil {
-			fatalf("Failed to convert master data from CSV data: %v\n%v", csvTable.fileName, err)
+		for _, finding := range findings {
+			if !c.fix || !finding.fixable {
+				c.log(filepath.Base(filePath) + ":" + finding.String())
+				problemCount++
+			}
+		}
+		if c.fix && fixed != nil {
+			encoded, err := encode(fixed, encoding)
+			if err != nil {
+				fatalf("Failed to encode CSV data: %v\n%v", filePath, err)
+			}
+			if isUTF8Charset(encoding) && !bytes.HasPrefix(data, utf8BOM) {
+				encoded = stripBOM(encoded)
+			}
+			c.writeFile("Fixed", filePath, encoded)
+		}
+	}
+	if problemCount > 0 {
+		fatalf("Lint found %v problems", problemCount)
+	}
+}
+
+func (c *Cli) masterDataList() []*MasterData {
+	var result []*MasterData
+	options := c.csvTableOptions()
+
+	for _, filePath := range c.csvFilePaths() {
+		// The variant files are overlaid on the original files instead of being converted.
+		if !isVariantCSVPath(filePath, c.variantNames()) {
+			result = append(result, c.masterData(filePath, options))
+		}
+	}
+
+	relationsPath := filepath.Join(c.schemaDir, "relations.yaml")
+	data, err := ioutil.ReadFile(relationsPath)
+	if os.IsNotExist(err) {
+		return result
+	} else if err != nil {
+		fatalf("Failed to read a file: %v\n%v", relationsPath, err)
+	}
+	relations, err := newRelations(data)
+	if err != nil {
+		fatalf("Failed to parse relations: %v\n%v", relationsPath, err)
+	}
+
+	// The child tables are loaded from the directory even if only the parent CSV file is given.
+	loaded := make(map[string]bool)
+	for _, masterData := range result {
+		loaded[masterData.tableName()] = true
+	}
+	for _, relation := range relations {
+		childPath := filepath.Join(c.dir, relation.Child+".csv")
+		if loaded[relation.Parent] && !loaded[relation.Child] {
+			if _, err := os.Stat(childPath); err == nil {
+				result = append(result, c.masterData(childPath, options))
+				loaded[relation.Child] = true
+			}
 		}
-		result[i] = masterData
+	}
+
+	result, err = joinMasterData(result, relations)
+	if err != nil {
+		fatalf("Failed to join child tables\n%v", err)
 	}
 	return result
 }
 
+func (c *Cli) masterData(filePath string, options *CSVTableOptions) *MasterData {
+	fileConfig := c.fileConfig(filePath)
+	options = fileConfig.csvTableOptions(options)
+	encoding, decoded := c.readCSVFile(filePath)
+
+	if c.variant != "" {
+		variantPath := variantCSVPath(filePath, c.variant)
+		if _, err := os.Stat(variantPath); err == nil {
+			_, options.variantData = c.readCSVFile(variantPath)
+			options.variantFileName = filepath.Base(variantPath)
+		}
+	}
+
+	csvTable, err := newCSVTableWithOptions(filePath, encoding, decoded, options)
+	if err != nil {
+		fatalf("Failed to parse CSV data: %v\n%v", filePath, err)
+	}
+
+	masterData, err := newMasterDataFromCSV(csvTable, fileConfig.indent(2))
+	if err != nil {
+		fatalf("Failed to convert master data from CSV data: %v\n%v", csvTable.fileName, err)
+	}
+	return masterData
+}
+
+// readCSVFile returns the encoding and the decoded data of the CSV file.
+func (c *Cli) readCSVFile(filePath string) (string, []byte) {
+	data := c.readFile(filePath)
+	encoding := c.encoding
+	if encoding == "auto" {
+		encoding = c.detectEncoding(filePath, data)
+	}
+	return encoding, c.decode(filePath, encoding, data)
+}
+
+// fileConfig returns the configuration of the file in the project configuration, which is empty if it's not given.
+func (c *Cli) fileConfig(filePath string) *FileConfig {
+	if fileConfig, ok := c.fileConfigs[filepath.Base(filePath)]; ok {
+		return fileConfig
+	}
+	return &FileConfig{}
+}
+
+func (c *Cli) csvTableOptions() *CSVTableOptions {
+	location := c.location
+	if location == nil {
+		location = time.UTC
+	}
+	options := &CSVTableOptions{location: location, datetimeFormat: c.datetimeFormat, booleans: c.booleanLiterals}
+
+	enumsPath := filepath.Join(c.schemaDir, "enums.yaml")
+	if data, err := ioutil.ReadFile(enumsPath); err == nil {
+		if options.enums, err = newEnums(data); err != nil {
+			fatalf("Failed to parse enums: %v\n%v", enumsPath, err)
+		}
+	} else if !os.IsNotExist(err) {
+		fatalf("Failed to read a file: %v\n%v", enumsPath, err)
+	}
+	return options
+}
+
+// variantNames returns the names of the configured variants and the active variant,
+// whose files are overlaid on the original files instead of being converted.
+func (c *Cli) variantNames() []string {
+	if c.variant == "" {
+		return c.variants
+	}
+	return append(append([]string{}, c.variants...), c.variant)
+}
+
 func (c *Cli) csvFilePaths() []string {
 	if c.hasSingleCSVFile() {
 		return []string{c.file}
@@ -175,6 +586,29 @@ func (c *Cli) csvFilePaths() []string {
 	return filePaths
 }
 
+func (c *Cli) jsonFilePaths() []string {
+	if c.file != "" {
+		return []string{c.file}
+	}
+	filePaths, err := filepath.Glob(filepath.Join(c.dir, "*.json"))
+	if err != nil {
+		fatalf("Failed to find JSON paths: %v\n%v", c.dir, err)
+	}
+
+	// The schemas and the string tables are not master data. The outputs of the variants are in the directories,
+	// which are skipped as well as the files of other directories.
+	var result []string
+	for _, filePath := range filePaths {
+		if info, err := os.Stat(filePath); err == nil && info.IsDir() {
+			continue
+		}
+		if !strings.HasSuffix(filePath, ".schema.json") && !isStringTableJSONPath(filePath) {
+			result = append(result, filePath)
+		}
+	}
+	return result
+}
+
 func (c *Cli) hasSingleCSVFile() bool {
 	return c.file != "" && strings.HasSuffix(c.file, ".csv")
 }
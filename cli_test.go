@@ -1,9 +1,11 @@
 package main
 
 import (
+	"github.com/jeffail/gabs"
 	. "github.com/smartystreets/goconvey/convey"
 	"io/ioutil"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -32,6 +34,39 @@ func TestCli(t *testing.T) {
 				So(actual, ShouldResemble, expected)
 			})
 
+			Convey("with stream option", func() {
+				cli.stream = true
+
+				Convey("should output the same JSON files", func() {
+					cli.run()
+					actual, err := ioutil.ReadFile("./.tmp/masterdata.json")
+					So(err, ShouldBeNil)
+					expected, err := ioutil.ReadFile("./fixtures/masterdata.json")
+					So(err, ShouldBeNil)
+					So(string(actual), ShouldEqual, string(expected))
+					_, err = os.Stat("./.tmp/masterdata.json.tmp")
+					So(os.IsNotExist(err), ShouldBeTrue)
+				})
+			})
+
+			Convey("with jsonl format", func() {
+				cli.format = "jsonl"
+
+				Convey("should output JSON Lines files validated by the items subschema", func() {
+					cli.schemaDir = "./fixtures"
+					cli.run()
+					actual, err := ioutil.ReadFile("./.tmp/masterdata.jsonl")
+					So(err, ShouldBeNil)
+					lines := strings.Split(strings.TrimSuffix(string(actual), "\n"), "\n")
+					expected, err := gabs.ParseJSONFile("./fixtures/masterdata.json")
+					So(err, ShouldBeNil)
+					So(lines, ShouldHaveLength, len(expected.Data().([]interface{})))
+					record, err := gabs.ParseJSON([]byte(lines[0]))
+					So(err, ShouldBeNil)
+					So(record.String(), ShouldEqual, expected.Index(0).String())
+				})
+			})
+
 			Convey("with fixEncoding option", func() {
 				cli.fixEncoding = true
 
@@ -71,6 +106,61 @@ func TestCli(t *testing.T) {
 				})
 			})
 
+			Convey("with outputSchema and strict schemaStrictness option", func() {
+				cli.outputSchema = true
+				cli.schemaStrictness = strictSchemaStrictness
+
+				Convey("should output JSON Schema files which have constraints", func() {
+					cli.run()
+					actual, err := ioutil.ReadFile("./.tmp/masterdata.schema.json")
+					So(err, ShouldBeNil)
+					schema, err := gabs.ParseJSON(actual)
+					So(err, ShouldBeNil)
+					So(schema.Path("items.properties.gender.enum").String(), ShouldEqual, `["female","male"]`)
+				})
+			})
+
+			Convey("with updateSchema option", func() {
+				cli.updateSchema = true
+				ioutil.WriteFile("./.tmp/masterdata.schema.json", []byte(`{
+					"type": "array",
+					"items": {
+						"type": "object",
+						"properties": { "id": { "type": "integer", "minimum": 1 } }
+					}
+				}`), 0777)
+
+				Convey("should merge inferred JSON Schema into the existing file", func() {
+					cli.run()
+					actual, err := ioutil.ReadFile("./.tmp/masterdata.schema.json")
+					So(err, ShouldBeNil)
+					schema, err := gabs.ParseJSON(actual)
+					So(err, ShouldBeNil)
+					So(schema.Path("items.properties.id").String(), ShouldEqual, `{"minimum":1,"type":"integer"}`)
+					So(schema.Path("items.properties.voice_actors.type").Data(), ShouldEqual, "array")
+				})
+			})
+
+			Convey("with outputTypeScript option", func() {
+				cli.outputTypeScript = true
+
+				Convey("should output TypeScript type definition files", func() {
+					cli.run()
+					actual, err := ioutil.ReadFile("./.tmp/masterdata.d.ts")
+					So(err, ShouldBeNil)
+					expected, err := ioutil.ReadFile("./fixtures/masterdata.d.ts")
+					So(err, ShouldBeNil)
+					So(string(actual), ShouldEqual, string(expected))
+				})
+
+				Convey("should name the files by the table even if the output directory contains .json", func() {
+					cli.outputDir = "./.tmp/x.json.out"
+					cli.run()
+					_, err := os.Stat("./.tmp/x.json.out/masterdata.d.ts")
+					So(err, ShouldBeNil)
+				})
+			})
+
 			Convey("with noOutputFile option", func() {
 				cli.noOutputFile = true
 
@@ -81,6 +171,129 @@ func TestCli(t *testing.T) {
 				})
 			})
 
+			Convey("with fileConfigs", func() {
+				ioutil.WriteFile("./.tmp/items.csv", []byte("id,name,memo\n1,Sword,old\n2,Shield,\n"), 0777)
+				indent := 0
+				cli.file = "./.tmp/items.csv"
+				cli.fileConfigs = map[string]*FileConfig{
+					"items.csv": &FileConfig{Indent: &indent, SortBy: "id", SortOrder: "desc", IgnoreColumns: []string{"memo"}},
+				}
+
+				Convey("should apply the configuration of the file", func() {
+					cli.run()
+					actual, err := ioutil.ReadFile("./.tmp/items.json")
+					So(err, ShouldBeNil)
+					So(string(actual), ShouldEqual, `[{"id":2,"name":"Shield"},{"id":1,"name":"Sword"}]`)
+				})
+			})
+
+			Convey("with variant option", func() {
+				ioutil.WriteFile("./.tmp/items.csv", []byte("id,name,drop_rate\n1,Sword,0.1\n2,Shield,0.2\n"), 0777)
+				ioutil.WriteFile("./.tmp/items.dev.csv", []byte("id,drop_rate\n2,1\n"), 0777)
+				os.Remove("./.tmp/masterdata.csv")
+				cli.dir = "./.tmp"
+				cli.file = ""
+				cli.variant = "dev"
+
+				Convey("should overlay the variant files, and not convert them", func() {
+					masterDataList := cli.masterDataList()
+					So(len(masterDataList), ShouldEqual, 1)
+					So(masterDataList[0].container.String(), ShouldEqual,
+						`[{"drop_rate":0.1,"id":1,"name":"Sword"},{"drop_rate":1,"id":2,"name":"Shield"}]`)
+				})
+			})
+
+			Convey("with a file whose suffix is not a variant", func() {
+				ioutil.WriteFile("./.tmp/items.csv", []byte("id,name\n1,Sword\n"), 0777)
+				ioutil.WriteFile("./.tmp/items.v2.csv", []byte("id,name\n1,Shield\n"), 0777)
+				os.Remove("./.tmp/masterdata.csv")
+				cli.dir = "./.tmp"
+				cli.file = ""
+				cli.variants = []string{"dev"}
+
+				Convey("should convert the file", func() {
+					masterDataList := cli.masterDataList()
+					So(len(masterDataList), ShouldEqual, 2)
+					So(masterDataList[1].tableName(), ShouldEqual, "items.v2")
+				})
+			})
+
+			Convey("with locales option", func() {
+				ioutil.WriteFile("./.tmp/items.csv", []byte("id,name.ja,name.en\n1,剣,Sword\n"), 0777)
+				cli.file = "./.tmp/items.csv"
+				cli.locales = []string{"ja", "en"}
+
+				Convey("should output the JSON without the localized values and the string tables", func() {
+					cli.run()
+					actual, err := ioutil.ReadFile("./.tmp/items.json")
+					So(err, ShouldBeNil)
+					items, err := gabs.ParseJSON(actual)
+					So(err, ShouldBeNil)
+					So(items.String(), ShouldEqual, `[{"id":1}]`)
+					actual, err = ioutil.ReadFile("./.tmp/items.ja.json")
+					So(err, ShouldBeNil)
+					So(string(actual), ShouldEqual, "{\n  \"1.name\": \"剣\"\n}")
+				})
+			})
+
+			Convey("with relations.yaml", func() {
+				ioutil.WriteFile("./.tmp/characters.csv", []byte("id,name\n1,Alice\n2,Bob"), 0777)
+				ioutil.WriteFile("./.tmp/character_skills.csv", []byte("character_id,name\n1,Fire\n1,Ice"), 0777)
+				ioutil.WriteFile("./.tmp/relations.yaml",
+					[]byte("- parent: characters\n  child: character_skills\n  foreign_key: character_id\n  as: skills\n"), 0777)
+				cli.file = "./.tmp/characters.csv"
+				cli.dir = "./.tmp"
+
+				Convey("should embed the child table into the parent JSON", func() {
+					cli.run()
+					actual, err := ioutil.ReadFile("./.tmp/characters.json")
+					So(err, ShouldBeNil)
+					characters, err := gabs.ParseJSON(actual)
+					So(err, ShouldBeNil)
+					So(characters.String(), ShouldEqual,
+						`[{"id":1,"name":"Alice","skills":[{"name":"Fire"},{"name":"Ice"}]},{"id":2,"name":"Bob","skills":[]}]`)
+					_, err = ioutil.ReadFile("./.tmp/character_skills.json")
+					So(err, ShouldNotBeNil)
+				})
+			})
+
+			Reset(func() {
+				os.RemoveAll("./.tmp")
+			})
+		})
+
+		Convey("#exportCSV", func() {
+			os.MkdirAll("./.tmp", 0777)
+			cli.file = "./fixtures/masterdata.json"
+
+			Convey("should output CSV files", func() {
+				cli.exportCSV()
+				actual, err := ioutil.ReadFile("./.tmp/masterdata.csv")
+				So(err, ShouldBeNil)
+				So(string(actual), ShouldStartWith, "\xef\xbb\xbfid,age,gender,items.0.count,")
+			})
+
+			Reset(func() {
+				os.RemoveAll("./.tmp")
+			})
+		})
+
+		Convey("#lint", func() {
+			os.MkdirAll("./.tmp", 0777)
+			ioutil.WriteFile("./.tmp/items.csv", []byte("id,name\n1, ソード \n"), 0777)
+			cli.file = "./.tmp/items.csv"
+
+			Convey("with fix option", func() {
+				cli.fix = true
+
+				Convey("should fix the CSV file in the original encoding", func() {
+					cli.lint()
+					actual, err := ioutil.ReadFile("./.tmp/items.csv")
+					So(err, ShouldBeNil)
+					So(string(actual), ShouldEqual, "id,name\n1,ソード\n")
+				})
+			})
+
 			Reset(func() {
 				os.RemoveAll("./.tmp")
 			})
@@ -107,5 +320,22 @@ func TestCli(t *testing.T) {
 				})
 			})
 		})
+
+		Convey("#jsonFilePaths", func() {
+			Convey("should return target JSON file paths without schemas, string tables and directories", func() {
+				os.MkdirAll("./.tmp/en.json", 0777)
+				for _, fileName := range []string{"items.json", "items.schema.json", "items.ja.json", "users.v2.json"} {
+					ioutil.WriteFile("./.tmp/"+fileName, []byte("[]"), 0777)
+				}
+				cli.dir = "./.tmp"
+
+				actual := cli.jsonFilePaths()
+				So(actual, ShouldResemble, []string{".tmp/items.json", ".tmp/users.v2.json"})
+			})
+
+			Reset(func() {
+				os.RemoveAll("./.tmp")
+			})
+		})
 	})
 }
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// configFileNames are the names of the project configuration file at the data root, in order of priority.
+var configFileNames = []string{"master.yaml", "master.yml", "master.toml"}
+
+// Config represents the project configuration file, which sets the options of all files.
+// The options given by command line flags take priority over it.
+type Config struct {
+	OutputDirectory    string                 `yaml:"output_directory" toml:"output_directory"`
+	SchemaDirectory    string                 `yaml:"schema_directory" toml:"schema_directory"`
+	AssetDirectory     string                 `yaml:"asset_directory" toml:"asset_directory"`
+	Encoding           string                 `yaml:"encoding" toml:"encoding"`
+	FixEncoding        bool                   `yaml:"fix_encoding" toml:"fix_encoding"`
+	Timezone           string                 `yaml:"timezone" toml:"timezone"`
+	DatetimeFormat     string                 `yaml:"datetime_format" toml:"datetime_format"`
+	NoOutputFile       bool                   `yaml:"no_output_file" toml:"no_output_file"`
+	OutputSchema       bool                   `yaml:"output_schema" toml:"output_schema"`
+	SchemaStrictness   string                 `yaml:"schema_strictness" toml:"schema_strictness"`
+	UpdateSchema       bool                   `yaml:"update_schema" toml:"update_schema"`
+	SkipValidation     bool                   `yaml:"skip_validation" toml:"skip_validation"`
+	NoSchemaSuffix     bool                   `yaml:"no_schema_suffix" toml:"no_schema_suffix"`
+	OutputTypeScript   bool                   `yaml:"output_typescript" toml:"output_typescript"`
+	ReadonlyTypeScript bool                   `yaml:"readonly_typescript" toml:"readonly_typescript"`
+	OutputProto        bool                   `yaml:"output_proto" toml:"output_proto"`
+	OutputProtobuf     bool                   `yaml:"output_protobuf" toml:"output_protobuf"`
+	Variant            string                 `yaml:"variant" toml:"variant"`
+	Variants           []string               `yaml:"variants" toml:"variants"`
+	Locales            []string               `yaml:"locales" toml:"locales"`
+	DisabledLintRules  []string               `yaml:"disabled_lint_rules" toml:"disabled_lint_rules"`
+	Stream             bool                   `yaml:"stream" toml:"stream"`
+	Format             string                 `yaml:"format" toml:"format"`
+	BooleanLiterals    *BooleanLiterals       `yaml:"boolean_literals" toml:"boolean_literals"`
+	Files              map[string]*FileConfig `yaml:"files" toml:"files"`
+}
+
+// FileConfig represents the options of a CSV file, which is given by the file name in Config.
+// The boolean literals are given by the column names.
+type FileConfig struct {
+	Indent          *int                        `yaml:"indent" toml:"indent"`
+	KeyColumn       string                      `yaml:"key_column" toml:"key_column"`
+	SortBy          string                      `yaml:"sort_by" toml:"sort_by"`
+	SortOrder       string                      `yaml:"sort_order" toml:"sort_order"`
+	IgnoreColumns   []string                    `yaml:"ignore_columns" toml:"ignore_columns"`
+	BooleanLiterals map[string]*BooleanLiterals `yaml:"boolean_literals" toml:"boolean_literals"`
+}
+
+// loadConfig finds the configuration file in the directory, and returns nil if it doesn't exist.
+// Relative directories in the file are resolved from the directory.
+func loadConfig(dir string) (*Config, string, error) {
+	for _, fileName := range configFileNames {
+		path := filepath.Join(dir, fileName)
+		data, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, path, err
+		}
+
+		config, err := newConfig(fileName, data)
+		if err != nil {
+			return nil, path, err
+		}
+		for _, configDir := range []*string{&config.OutputDirectory, &config.SchemaDirectory, &config.AssetDirectory} {
+			if *configDir != "" && !filepath.IsAbs(*configDir) {
+				*configDir = filepath.Join(dir, *configDir)
+			}
+		}
+		return config, path, nil
+	}
+	return nil, "", nil
+}
+
+func newConfig(fileName string, data []byte) (*Config, error) {
+	config := &Config{}
+	if filepath.Ext(fileName) == ".toml" {
+		metaData, err := toml.Decode(string(data), config)
+		if err != nil {
+			return nil, err
+		}
+		if undecoded := metaData.Undecoded(); len(undecoded) > 0 {
+			return nil, fmt.Errorf("Unknown keys: %v", undecoded)
+		}
+	} else if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, err
+	}
+
+	if config.BooleanLiterals != nil {
+		if err := config.BooleanLiterals.validate(); err != nil {
+			return nil, err
+		}
+	}
+	for fileName, fileConfig := range config.Files {
+		if fileConfig == nil {
+			config.Files[fileName] = &FileConfig{}
+			continue
+		}
+		if fileConfig.SortOrder != "" && fileConfig.SortOrder != "asc" && fileConfig.SortOrder != "desc" {
+			return nil, fmt.Errorf("Sort order should be asc or desc: %v", fileName)
+		}
+		if fileConfig.Indent != nil && *fileConfig.Indent < 0 {
+			return nil, fmt.Errorf("Indent should not be negative: %v", fileName)
+		}
+		for columnName, booleans := range fileConfig.BooleanLiterals {
+			if booleans == nil {
+				return nil, fmt.Errorf("Boolean literals should have true_values and false_values: %v %v", fileName, columnName)
+			}
+			if err := booleans.validate(); err != nil {
+				return nil, fmt.Errorf("%v: %v %v", err, fileName, columnName)
+			}
+		}
+	}
+	return config, nil
+}
+
+// csvTableOptions returns a copy of the options which the file configuration is applied to.
+func (f *FileConfig) csvTableOptions(options *CSVTableOptions) *CSVTableOptions {
+	result := *options
+	result.keyColumn = f.KeyColumn
+	result.sortBy = f.SortBy
+	result.sortDescending = f.SortOrder == "desc"
+	result.ignoreColumns = f.IgnoreColumns
+	result.columnBooleans = f.BooleanLiterals
+	return &result
+}
+
+func (f *FileConfig) indent(defaultIndent int) int {
+	if f.Indent == nil {
+		return defaultIndent
+	}
+	return *f.Indent
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfig(t *testing.T) {
+	Convey("Config", t, func() {
+		Convey(".newConfig", func() {
+			Convey("with YAML", func() {
+				Convey("should return Config", func() {
+					config, err := newConfig("master.yaml", []byte(
+						"encoding: Shift_JIS\nskip_validation: true\nfiles:\n  items.csv:\n    indent: 4\n    sort_by: id\n    sort_order: desc\n"))
+					So(err, ShouldBeNil)
+					So(config.Encoding, ShouldEqual, "Shift_JIS")
+					So(config.SkipValidation, ShouldBeTrue)
+					So(*config.Files["items.csv"].Indent, ShouldEqual, 4)
+					So(config.Files["items.csv"].SortBy, ShouldEqual, "id")
+					So(config.Files["items.csv"].SortOrder, ShouldEqual, "desc")
+				})
+			})
+
+			Convey("with TOML", func() {
+				Convey("should return Config", func() {
+					config, err := newConfig("master.toml", []byte(
+						"timezone = \"Asia/Tokyo\"\n\n[files.\"items.csv\"]\nkey_column = \"code\"\nignore_columns = [\"memo*\"]\n"))
+					So(err, ShouldBeNil)
+					So(config.Timezone, ShouldEqual, "Asia/Tokyo")
+					So(config.Files["items.csv"].KeyColumn, ShouldEqual, "code")
+					So(config.Files["items.csv"].IgnoreColumns, ShouldResemble, []string{"memo*"})
+				})
+			})
+
+			Convey("with unknown keys", func() {
+				Convey("should return error", func() {
+					_, err := newConfig("master.yaml", []byte("encodings: utf-8\n"))
+					So(err, ShouldNotBeNil)
+					_, err = newConfig("master.toml", []byte("encodings = \"utf-8\"\n"))
+					So(err, ShouldNotBeNil)
+				})
+			})
+
+			Convey("with boolean literals", func() {
+				Convey("should return Config which has the literals of the project and the columns", func() {
+					config, err := newConfig("master.yaml", []byte("boolean_literals:\n  true_values: [TRUE, ○]\n  false_values: [FALSE, ×]\n"+
+						"files:\n  items.csv:\n    boolean_literals:\n      is_rare: {true_values: [\"1\"], false_values: [\"0\"]}\n"))
+					So(err, ShouldBeNil)
+					So(config.BooleanLiterals.TrueValues, ShouldResemble, []string{"TRUE", "○"})
+					So(config.Files["items.csv"].BooleanLiterals["is_rare"].FalseValues, ShouldResemble, []string{"0"})
+				})
+
+				Convey("should return error with conflicting literals", func() {
+					_, err := newConfig("master.yaml", []byte("boolean_literals:\n  true_values: [\"1\"]\n  false_values: [\"1\"]\n"))
+					So(err, ShouldNotBeNil)
+				})
+			})
+
+			Convey("with invalid sort order", func() {
+				Convey("should return error", func() {
+					_, err := newConfig("master.yaml", []byte("files:\n  items.csv:\n    sort_order: descending\n"))
+					So(err, ShouldNotBeNil)
+				})
+			})
+		})
+
+		Convey(".loadConfig", func() {
+			dir := "./.tmp"
+			os.MkdirAll(dir, 0777)
+
+			Convey("without configuration file", func() {
+				Convey("should return nil", func() {
+					config, _, err := loadConfig(dir)
+					So(err, ShouldBeNil)
+					So(config, ShouldBeNil)
+				})
+			})
+
+			Convey("with configuration file", func() {
+				Convey("should resolve relative directories from the directory", func() {
+					ioutil.WriteFile(filepath.Join(dir, "master.yaml"), []byte("output_directory: out\n"), 0777)
+					config, path, err := loadConfig(dir)
+					So(err, ShouldBeNil)
+					So(path, ShouldEqual, filepath.Join(dir, "master.yaml"))
+					So(config.OutputDirectory, ShouldEqual, filepath.Join(dir, "out"))
+				})
+			})
+
+			Reset(func() {
+				os.RemoveAll("./.tmp")
+			})
+		})
+	})
+}
@@ -3,71 +3,420 @@ package main
 import (
 	"bytes"
 	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
+const maxArrayIndex = 65535
+
+const (
+	datetimeColumnKind = "datetime"
+	listColumnKind     = "list"
+	jsonColumnKind     = "json"
+	stringColumnKind   = "string"
+)
+
+const listSeparator = "|"
+
+// multiRowArrayKey is the array index of columns whose values are appended across multi-row records.
+const multiRowArrayKey = "*"
+
 var (
 	numberValuePattern = regexp.MustCompile("^[0-9]+\\.?[0-9]*$")
-	boolValuePattern   = regexp.MustCompile("^(TRUE|FALSE)$")
 	csvColumnPattern   = regexp.MustCompile("^[^0-9.]+(\\.[^.]+)*$")
 )
 
+var csvColumnKinds = map[string]bool{
+	datetimeColumnKind: true,
+	listColumnKind:     true,
+	jsonColumnKind:     true,
+	stringColumnKind:   true,
+}
+
 // CSVColumn represents a column of CSVTable.
+// The kind is given by the column name suffix, e.g. "start_at:datetime" or "rarity:Rarity" for enums.
+// The suffix must be datetime, list, json, string or an enum name, otherwise the header is invalid.
+// The types of columns without kinds are detected from the values: hasLiterals, hasTextLiterals and hasNumbers
+// are the evidences of boolean literals, boolean literals which are not numbers, and numbers which are not literals.
 type CSVColumn struct {
-	index    int
-	name     string
-	isString bool
-	isBool   bool
+	index           int
+	name            string
+	kind            string
+	isString        bool
+	isBool          bool
+	hasLiterals     bool
+	hasTextLiterals bool
+	hasNumbers      bool
+}
+
+// csvRawValue wraps a value of list or json columns while building rows,
+// so that the items of it are not removed as empty array items.
+type csvRawValue struct {
+	value interface{}
+}
+
+// CSVTableOptions represents how CSVTable reads columns and converts values of typed columns.
+// The key column, the sort column and the ignored columns are given per file by the project configuration,
+// and the variant data is the decoded CSV data which overlays the data.
+type CSVTableOptions struct {
+	location        *time.Location
+	datetimeFormat  DatetimeFormat
+	enums           map[string]*Enum
+	keyColumn       string
+	sortBy          string
+	sortDescending  bool
+	ignoreColumns   []string
+	variantData     []byte
+	variantFileName string
+	booleans        *BooleanLiterals
+	columnBooleans  map[string]*BooleanLiterals
+}
+
+// booleanLiterals returns the boolean literals of the column, which are given per column, per project or by default.
+func (o *CSVTableOptions) booleanLiterals(columnName string) *BooleanLiterals {
+	if booleans, ok := o.columnBooleans[columnName]; ok {
+		return booleans
+	} else if o.booleans != nil {
+		return o.booleans.withoutNumbers()
+	}
+	return defaultBooleanLiterals
 }
 
-func newCSVColumns(records [][]string) ([]*CSVColumn, error) {
-	columnLength := len(records[0])
-	columns := make([]*CSVColumn, columnLength)
-	for i, value := range records[0] {
+func newCSVColumns(records [][]string, options *CSVTableOptions) ([]*CSVColumn, error) {
+	columns, err := newCSVHeaderColumns(records[0], options)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records[1:] {
+		if err := detectCSVColumnTypes(columns, record, options); err != nil {
+			return nil, err
+		}
+	}
+	return columns, nil
+}
+
+// newCSVHeaderColumns returns the columns of the header, whose types are detected by detectCSVColumnTypes later.
+func newCSVHeaderColumns(header []string, options *CSVTableOptions) ([]*CSVColumn, error) {
+	columns := make([]*CSVColumn, len(header))
+	for i, value := range header {
 		columns[i] = &CSVColumn{index: i, name: value}
+		if separatorIndex := strings.LastIndex(value, ":"); separatorIndex >= 0 {
+			kind := value[separatorIndex+1:]
+			if !csvColumnKinds[kind] && options.enums[kind] == nil {
+				return nil, fmt.Errorf("Unknown kind of column %v: %v", value[:separatorIndex], kind)
+			}
+			columns[i].name = value[:separatorIndex]
+			columns[i].kind = kind
+			columns[i].isString = kind == stringColumnKind
+		}
 
 		if err := columns[i].validate(); err != nil {
 			return nil, err
 		}
 	}
+	if err := validateCSVColumnPaths(columns); err != nil {
+		return nil, err
+	}
+	keyIndex := csvColumnIndex(columns, options.keyColumn)
+	if keyIndex < 0 {
+		return nil, fmt.Errorf("Key column is not found: %v", options.keyColumn)
+	}
+	if columns[keyIndex].isMultiRow() {
+		return nil, fmt.Errorf("Column %v is the key of multi-row records, so it cannot be in a multi-row array",
+			columns[keyIndex].name)
+	}
+	if options.sortBy != "" && csvColumnIndex(columns, options.sortBy) < 0 {
+		return nil, fmt.Errorf("Sort column is not found: %v", options.sortBy)
+	}
+	return columns, nil
+}
 
-	for _, record := range records[1:] {
-		if len(record) != columnLength {
-			return nil, fmt.Errorf("Record length is not enough: %v", record)
+func detectCSVColumnTypes(columns []*CSVColumn, record []string, options *CSVTableOptions) error {
+	if len(record) != len(columns) {
+		return fmt.Errorf("Record length is not enough: %v", record)
+	}
+	for i, value := range record {
+		booleans := options.booleanLiterals(columns[i].name)
+		switch columns[i].kind {
+		case "":
+			columns[i].detectType(value, booleans)
+		case listColumnKind:
+			for _, item := range splitListCell(value) {
+				columns[i].detectType(item, booleans)
+			}
 		}
-		for i, value := range record {
-			if value == "" || numberValuePattern.MatchString(value) {
-				continue
-			} else if boolValuePattern.MatchString(value) {
-				columns[i].isBool = true
-			} else {
-				columns[i].isString = true
+	}
+	return nil
+}
+
+// csvColumnIndex returns the index of the column which has the name, or -1 if it's not found.
+// The first column is returned if the name is empty.
+func csvColumnIndex(columns []*CSVColumn, name string) int {
+	if name == "" {
+		return 0
+	}
+	for i, column := range columns {
+		if column.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// isCommentCSVHeader reports whether the column is a comment for planners, e.g. "#memo" or "// memo".
+func isCommentCSVHeader(header string) bool {
+	return strings.HasPrefix(header, "#") || strings.HasPrefix(header, "//")
+}
+
+// isDisabledCSVRecord reports whether the row is disabled by a leading "#" in the first cell.
+func isDisabledCSVRecord(record []string) bool {
+	return len(record) > 0 && strings.HasPrefix(record[0], "#")
+}
+
+// removeDisabledCSVRecords removes the disabled rows, and returns the line numbers of the remaining rows.
+func removeDisabledCSVRecords(records [][]string) ([][]string, []csvLine) {
+	result := [][]string{records[0]}
+	lines := make([]csvLine, 0, len(records)-1)
+	for i, record := range records[1:] {
+		if !isDisabledCSVRecord(record) {
+			result = append(result, record)
+			lines = append(lines, csvLine{number: i + 2})
+		}
+	}
+	return result, lines
+}
+
+// csvLine represents the line number of a row in the CSV data for errors.
+// The line number in the variant CSV data is also given if the row is added or overlaid by the variant data.
+type csvLine struct {
+	number          int
+	variantNumber   int
+	variantFileName string
+}
+
+func (l csvLine) String() string {
+	if l.variantFileName == "" {
+		return strconv.Itoa(l.number)
+	} else if l.number == 0 {
+		return fmt.Sprintf("%v of %v", l.variantNumber, l.variantFileName)
+	}
+	return fmt.Sprintf("%v (overlaid by line %v of %v)", l.number, l.variantNumber, l.variantFileName)
+}
+
+// removeIgnoredCSVColumns removes the comment columns and the columns whose header matches any of the patterns,
+// e.g. "memo*".
+func removeIgnoredCSVColumns(records [][]string, patterns []string) ([][]string, error) {
+	indexes, err := csvColumnIndexesWithoutIgnored(records[0], patterns)
+	if err != nil {
+		return nil, err
+	}
+	result := make([][]string, len(records))
+	for i, record := range records {
+		result[i] = selectCSVColumns(record, indexes)
+	}
+	return result, nil
+}
+
+// csvHeaderName returns the column name of the header without the kind, e.g. "id" of "id:string".
+func csvHeaderName(header string) string {
+	if separatorIndex := strings.LastIndex(header, ":"); separatorIndex >= 0 {
+		return header[:separatorIndex]
+	}
+	return header
+}
+
+// csvColumnIndexesWithoutIgnored returns the indexes of the columns which are not ignored.
+// The patterns match the column names with or without the kinds, e.g. "memo*" matches "memo:json".
+func csvColumnIndexesWithoutIgnored(header []string, patterns []string) ([]int, error) {
+	var indexes []int
+	for i, value := range header {
+		isIgnored := isCommentCSVHeader(value)
+		name := csvHeaderName(value)
+		for _, pattern := range patterns {
+			matched, err := path.Match(pattern, value)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid pattern of ignored columns: %v", pattern)
 			}
+			matchedName, _ := path.Match(pattern, name)
+			isIgnored = isIgnored || matched || matchedName
+		}
+		if !isIgnored {
+			indexes = append(indexes, i)
 		}
 	}
-	return columns, nil
+	if len(indexes) == 0 {
+		return nil, fmt.Errorf("All columns are ignored: %v", strings.Join(header, ","))
+	}
+	return indexes, nil
+}
+
+func selectCSVColumns(record []string, indexes []int) []string {
+	result := make([]string, 0, len(indexes))
+	for _, index := range indexes {
+		if index < len(record) {
+			result = append(result, record[index])
+		}
+	}
+	return result
+}
+
+// detectType detects the type from the value. The column is boolean only if all of its values are boolean literals.
+// If it has both literals and numbers, it's a number column if the literals are numbers, e.g. "1" and "0",
+// otherwise it's a string column.
+func (c *CSVColumn) detectType(value string, booleans *BooleanLiterals) {
+	if value == "" {
+		return
+	}
+	_, isLiteral := booleans.parse(value)
+	isNumber := numberValuePattern.MatchString(value)
+	switch {
+	case isLiteral:
+		c.hasLiterals = true
+		c.hasTextLiterals = c.hasTextLiterals || !isNumber
+	case isNumber:
+		c.hasNumbers = true
+	default:
+		c.isString = true
+	}
+	c.isString = c.isString || (c.hasNumbers && c.hasTextLiterals)
+	c.isBool = !c.isString && c.hasLiterals && !c.hasNumbers
+}
+
+func (c *CSVColumn) scalarValue(value string, booleans *BooleanLiterals) (interface{}, error) {
+	if c.isString {
+		return value, nil
+	} else if c.isBool {
+		boolValue, ok := booleans.parse(value)
+		if !ok && value != "" {
+			return nil, fmt.Errorf("Not a boolean literal: %v", value)
+		}
+		return boolValue, nil
+	} else if value == "" {
+		return 0, nil
+	}
+	floatValue, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, fmt.Errorf("Not a number: %v", value)
+	}
+	return floatValue, nil
+}
+
+// isMultiRow reports whether the values of the column are appended across multi-row records.
+func (c *CSVColumn) isMultiRow() bool {
+	for _, key := range strings.Split(c.name, ".") {
+		if key == multiRowArrayKey {
+			return true
+		}
+	}
+	return false
+}
+
+// isRaw reports whether the values of the column are arrays or objects which are written in a cell.
+func (c *CSVColumn) isRaw() bool {
+	return c.kind == listColumnKind || c.kind == jsonColumnKind
 }
 
 func (c *CSVColumn) validate() error {
 	if !csvColumnPattern.MatchString(c.name) {
 		return fmt.Errorf("Invalid column name: %v", c.name)
 	}
+	if strings.Count("."+c.name+".", "."+multiRowArrayKey+".") > 1 {
+		return fmt.Errorf("Column %v has nested multi-row arrays", c.name)
+	}
+	return nil
+}
+
+type csvColumnPathNode struct {
+	column       *CSVColumn
+	childColumn  *CSVColumn
+	childKeyKind string
+	children     map[string]*csvColumnPathNode
+}
+
+// validateCSVColumnPaths detects column names which cannot be built into the same structure,
+// e.g. "a" and "a.b", or "items.0" and "items.foo".
+func validateCSVColumnPaths(columns []*CSVColumn) error {
+	root := &csvColumnPathNode{children: make(map[string]*csvColumnPathNode)}
+
+	for _, column := range columns {
+		node := root
+		keys := strings.Split(column.name, ".")
+
+		for i, key := range keys {
+			path := strings.Join(keys[:i], ".")
+			if node.column != nil {
+				return fmt.Errorf("Column %v conflicts with %v: %v has a value, so it cannot have nested keys",
+					column.name, node.column.name, path)
+			}
+			if node.childColumn == nil {
+				node.childColumn = column
+				node.childKeyKind = csvColumnKeyKind(key)
+			} else if node.childKeyKind != csvColumnKeyKind(key) {
+				return fmt.Errorf("Column %v conflicts with %v: %v is used as both %v and %v",
+					column.name, node.childColumn.name, path, node.childKeyKind, csvColumnKeyKind(key))
+			}
+
+			if isArrayIndex(key) {
+				// "items.0" and "items.00" point to the same array item.
+				index, _ := strconv.Atoi(key)
+				if index > maxArrayIndex {
+					return fmt.Errorf("Column %v has too large array index: %v (maximum is %v)",
+						column.name, key, maxArrayIndex)
+				}
+				key = strconv.Itoa(index)
+			}
+			child, ok := node.children[key]
+			if !ok {
+				child = &csvColumnPathNode{children: make(map[string]*csvColumnPathNode)}
+				node.children[key] = child
+			}
+			node = child
+		}
+
+		if node.column != nil {
+			return fmt.Errorf("Column %v is duplicated", column.name)
+		}
+		if node.childColumn != nil {
+			return fmt.Errorf("Column %v conflicts with %v: %v has nested keys, so it cannot have a value",
+				column.name, node.childColumn.name, column.name)
+		}
+		node.column = column
+	}
 	return nil
 }
 
+func csvColumnKeyKind(key string) string {
+	if key == multiRowArrayKey {
+		return "a multi-row array"
+	} else if isArrayIndex(key) {
+		return "an array"
+	}
+	return "an object"
+}
+
 // CSVTable represents structured CSV data table.
 type CSVTable struct {
 	fileName string
 	encoding string
 	columns  []*CSVColumn
 	rows     [][]interface{}
+	keyCells []string
+	lines    []csvLine
+	options  *CSVTableOptions
 }
 
 func newCSVTable(path string, encoding string, data []byte) (*CSVTable, error) {
+	return newCSVTableWithOptions(path, encoding, data, &CSVTableOptions{location: time.UTC})
+}
+
+func newCSVTableWithOptions(path string, encoding string, data []byte, options *CSVTableOptions) (*CSVTable, error) {
 	reader := csv.NewReader(bytes.NewReader(data))
 	records, err := reader.ReadAll()
 	if err != nil {
@@ -76,84 +425,297 @@ func newCSVTable(path string, encoding string, data []byte) (*CSVTable, error) {
 	if len(records) < 2 {
 		return nil, fmt.Errorf("CSV data should have 2 rows at a minimum: %v", path)
 	}
+	// The disabled rows are checked before removing the columns, so a comment column "#" can disable them.
+	var lines []csvLine
+	if options.variantData != nil {
+		if records, lines, err = overlayCSVRecords(records, options.variantData, options.variantFileName,
+			options.keyColumn); err != nil {
+			return nil, err
+		}
+	} else {
+		records, lines = removeDisabledCSVRecords(records)
+	}
+	if records, err = removeIgnoredCSVColumns(records, options.ignoreColumns); err != nil {
+		return nil, err
+	}
 
-	columns, err := newCSVColumns(records)
+	columns, err := newCSVColumns(records, options)
 	if err != nil {
 		return nil, err
 	}
 
 	rows := make([][]interface{}, len(records)-1)
+	keyCells := make([]string, len(records)-1)
+	keyIndex := csvColumnIndex(columns, options.keyColumn)
 	for recordIndex, record := range records[1:] {
-		row := make([]interface{}, len(record))
-		rows[recordIndex] = row
-
-		for i, value := range record {
-			strValue := fmt.Sprintf("%v", value)
-
-			if columns[i].isString {
-				row[i] = strValue
-			} else if columns[i].isBool {
-				row[i] = strValue == "TRUE"
-			} else if strValue == "" {
-				row[i] = 0
-			} else {
-				floatValue, err := strconv.ParseFloat(strValue, 64)
-				if err != nil {
-					return nil, err
-				}
-				row[i] = floatValue
-			}
+		if rows[recordIndex], err = convertCSVRecord(columns, record, lines[recordIndex], options); err != nil {
+			return nil, err
 		}
+		keyCells[recordIndex] = record[keyIndex]
 	}
 	csvTable := &CSVTable{
 		fileName: filepath.Base(path),
 		encoding: encoding,
 		columns:  columns,
 		rows:     rows,
+		keyCells: keyCells,
+		lines:    lines,
+		options:  options,
 	}
 	return csvTable, err
 }
 
-func (c *CSVTable) data() ([]map[string]interface{}, error) {
-	result := make([]map[string]interface{}, len(c.rows))
+// convertCSVRecord converts the values of the record to the values of the row by the column types.
+// The line is the line number of the record in the CSV data for errors.
+func convertCSVRecord(columns []*CSVColumn, record []string, line csvLine, options *CSVTableOptions) ([]interface{}, error) {
+	row := make([]interface{}, len(record))
+	for i, value := range record {
+		var err error
 
-	for rowIndex, row := range c.rows {
-		root := make(map[string]interface{})
-		result[rowIndex] = root
+		if columns[i].kind == datetimeColumnKind {
+			if value == "" {
+				row[i] = nil
+				continue
+			}
+			t, err := parseDatetime(value, options.location)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid datetime in line %v, column %v: %v", line, columns[i].name, value)
+			}
+			row[i] = options.datetimeFormat.value(t)
+		} else if enum, ok := options.enums[columns[i].kind]; ok {
+			if value == "" {
+				row[i] = nil
+				continue
+			}
+			enumValue, err := enum.value(value)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid enum in line %v, column %v: %v", line, columns[i].name, err)
+			}
+			row[i] = float64(enumValue)
+		} else if columns[i].kind == listColumnKind {
+			items := splitListCell(value)
+			list := make([]interface{}, len(items))
+			for j, item := range items {
+				if list[j], err = columns[i].scalarValue(item, options.booleanLiterals(columns[i].name)); err != nil {
+					return nil, fmt.Errorf("Invalid value in line %v, column %v: %v", line, columns[i].name, err)
+				}
+			}
+			row[i] = list
+		} else if columns[i].kind == jsonColumnKind {
+			if value == "" {
+				row[i] = nil
+				continue
+			}
+			var jsonValue interface{}
+			if err := json.Unmarshal([]byte(value), &jsonValue); err != nil {
+				return nil, fmt.Errorf("Invalid JSON in line %v, column %v: %v", line, columns[i].name, err)
+			}
+			row[i] = jsonValue
+		} else if row[i], err = columns[i].scalarValue(value, options.booleanLiterals(columns[i].name)); err != nil {
+			return nil, fmt.Errorf("Invalid value in line %v, column %v: %v", line, columns[i].name, err)
+		}
+	}
+	return row, nil
+}
+
+// columnSchemas returns the JSON Schema keywords of typed columns, which cannot be inferred from the values.
+func (c *CSVTable) columnSchemas() map[string]map[string]interface{} {
+	result := make(map[string]map[string]interface{})
+	for _, column := range c.columns {
+		if column.kind == datetimeColumnKind {
+			result[column.name] = c.options.datetimeFormat.schema()
+		} else if enum, ok := c.options.enums[column.kind]; ok {
+			result[column.name] = enum.schema()
+		}
+	}
+	return result
+}
 
-		for i, value := range row {
-			column := c.columns[i]
-			c.getMapData(root, strings.Split(column.name, "."), value)
+// keyColumn returns the name of the column which identifies the records.
+func (c *CSVTable) keyColumn() string {
+	return c.columns[csvColumnIndex(c.columns, c.options.keyColumn)].name
+}
+
+// enums returns the enums which are used by the columns.
+func (c *CSVTable) enums() map[string]*Enum {
+	result := make(map[string]*Enum)
+	for _, column := range c.columns {
+		if enum, ok := c.options.enums[column.kind]; ok {
+			result[enum.name] = enum
 		}
-		c.removeEmptyArrayItemRecursively(root)
+	}
+	return result
+}
+
+// data returns the rows built into the structure of the column names.
+// The records are sorted if the sort column is given.
+func (c *CSVTable) data() ([]map[string]interface{}, error) {
+	builder := newCSVRecordBuilder(c)
+	result := []map[string]interface{}{}
+	for rowIndex, row := range c.rows {
+		record, err := builder.add(row, c.keyCells[rowIndex], c.lines[rowIndex])
+		if err != nil {
+			return nil, err
+		}
+		if record != nil {
+			result = append(result, record)
+		}
+	}
+	if record := builder.flush(); record != nil {
+		result = append(result, record)
+	}
+
+	if c.options.sortBy != "" {
+		sort.SliceStable(result, func(i, j int) bool {
+			a, _ := lookupPath(result[i], c.options.sortBy)
+			b, _ := lookupPath(result[j], c.options.sortBy)
+			if c.options.sortDescending {
+				return compareCSVValues(b, a) < 0
+			}
+			return compareCSVValues(a, b) < 0
+		})
 	}
 	return result, nil
 }
 
+// csvRecordBuilder builds the rows into the structure of the column names one by one.
+// If there are multi-row columns, e.g. "steps.*.text", the rows whose key column is empty
+// continue the previous record, and the values of multi-row columns are appended to the arrays.
+// So a record is completed when the next record starts.
+type csvRecordBuilder struct {
+	table              *CSVTable
+	keyIndex           int
+	hasMultiRowColumns bool
+	root               map[string]interface{}
+	multiRowIndex      int
+}
+
+func newCSVRecordBuilder(table *CSVTable) *csvRecordBuilder {
+	builder := &csvRecordBuilder{table: table, keyIndex: csvColumnIndex(table.columns, table.options.keyColumn)}
+	for _, column := range table.columns {
+		builder.hasMultiRowColumns = builder.hasMultiRowColumns || column.isMultiRow()
+	}
+	return builder
+}
+
+// add builds the row, and returns the previous record if the row starts a new record.
+// The key cell is the raw value of the key column, because a key like "0" is converted to an empty value.
+// The line is the line number of the row in the CSV data for errors.
+func (b *csvRecordBuilder) add(row []interface{}, keyCell string, line csvLine) (map[string]interface{}, error) {
+	var completed map[string]interface{}
+	isContinued := b.hasMultiRowColumns && b.root != nil && keyCell == ""
+	if isContinued {
+		b.multiRowIndex++
+	} else {
+		completed = b.flush()
+		b.root = make(map[string]interface{})
+		b.multiRowIndex = 0
+	}
+
+	for i, value := range row {
+		column := b.table.columns[i]
+		keys := strings.Split(column.name, ".")
+		if column.isMultiRow() {
+			for j, key := range keys {
+				if key == multiRowArrayKey {
+					keys[j] = strconv.Itoa(b.multiRowIndex)
+				}
+			}
+		} else if isContinued {
+			if !isEmptyCSVValue(value) {
+				return nil, fmt.Errorf("Column %v should be empty in line %v, because the row continues the previous record",
+					column.name, line)
+			}
+			continue
+		}
+
+		if column.isRaw() {
+			value = csvRawValue{value: value}
+		}
+		b.table.getMapData(b.root, keys, value)
+	}
+	return completed, nil
+}
+
+// flush returns the record which is being built, or nil if there is no record.
+func (b *csvRecordBuilder) flush() map[string]interface{} {
+	root := b.root
+	if root != nil {
+		b.table.removeEmptyArrayItemRecursively(root)
+		unwrapCSVRawValues(root)
+		b.root = nil
+	}
+	return root
+}
+
+// compareCSVValues compares the values of a column. Null is less than any other value.
+func compareCSVValues(a interface{}, b interface{}) int {
+	if a == nil || b == nil {
+		if a == nil && b == nil {
+			return 0
+		} else if a == nil {
+			return -1
+		}
+		return 1
+	}
+
+	switch aValue := a.(type) {
+	case float64:
+		if bValue, ok := b.(float64); ok {
+			if aValue < bValue {
+				return -1
+			} else if aValue > bValue {
+				return 1
+			}
+			return 0
+		}
+	case bool:
+		if bValue, ok := b.(bool); ok {
+			if aValue == bValue {
+				return 0
+			} else if !aValue {
+				return -1
+			}
+			return 1
+		}
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
 func (c *CSVTable) removeEmptyArrayItemRecursively(container map[string]interface{}) {
 	for key, value := range container {
 		if valueAsMap, ok := value.(map[string]interface{}); ok {
 			c.removeEmptyArrayItemRecursively(valueAsMap)
 		} else if valueAsArray, ok := value.([]interface{}); ok {
-			array := []interface{}{}
-
-			for _, arrayItem := range valueAsArray {
-				if arrayItemAsMap, ok := arrayItem.(map[string]interface{}); ok {
-					for _, v := range arrayItemAsMap {
-						if v != 0 && v != "" && v != false {
-							array = append(array, arrayItem)
-							break
-						}
-					}
-				} else if arrayItemAsString, ok := arrayItem.(string); ok && arrayItemAsString != "" {
-					array = append(array, arrayItem)
-				} else if arrayItem != nil {
-					array = append(array, arrayItem)
-				}
+			container[key] = c.removeEmptyArrayItem(valueAsArray)
+		}
+	}
+}
+
+func (c *CSVTable) removeEmptyArrayItem(values []interface{}) []interface{} {
+	array := []interface{}{}
+
+	for _, arrayItem := range values {
+		if arrayItemAsMap, ok := arrayItem.(map[string]interface{}); ok {
+			c.removeEmptyArrayItemRecursively(arrayItemAsMap)
+			if !isEmptyCSVValue(arrayItemAsMap) {
+				array = append(array, arrayItem)
+			}
+		} else if arrayItemAsArray, ok := arrayItem.([]interface{}); ok {
+			if nestedArray := c.removeEmptyArrayItem(arrayItemAsArray); len(nestedArray) > 0 {
+				array = append(array, nestedArray)
+			}
+		} else if arrayItemAsString, ok := arrayItem.(string); ok && arrayItemAsString != "" {
+			array = append(array, arrayItem)
+		} else if arrayItemAsRaw, ok := arrayItem.(csvRawValue); ok {
+			if !isEmptyCSVValue(arrayItemAsRaw) {
+				array = append(array, arrayItem)
 			}
-			container[key] = array
+		} else if arrayItem != nil {
+			array = append(array, arrayItem)
 		}
 	}
+	return array
 }
 
 func (c *CSVTable) getMapData(container map[string]interface{},
@@ -166,7 +728,8 @@ func (c *CSVTable) getMapData(container map[string]interface{},
 
 	if len(keys) == 1 {
 		container[key] = value
-	} else if arrayIndex, err := strconv.Atoi(nextKey); err == nil && arrayIndex >= 0 {
+	} else if isArrayIndex(nextKey) {
+		arrayIndex, _ := strconv.Atoi(nextKey)
 		if container[key] == nil {
 			container[key] = make([]interface{}, arrayIndex+1)
 		}
@@ -194,7 +757,8 @@ func (c *CSVTable) getArrayData(array []interface{}, index int,
 		return array
 	}
 
-	if nextArrayIndex, err := strconv.Atoi(keys[0]); err == nil {
+	if isArrayIndex(keys[0]) {
+		nextArrayIndex, _ := strconv.Atoi(keys[0])
 		if array[index] == nil {
 			array[index] = make([]interface{}, nextArrayIndex+1)
 		}
@@ -208,3 +772,75 @@ func (c *CSVTable) getArrayData(array []interface{}, index int,
 	}
 	return array
 }
+
+// isEmptyCSVValue reports whether the value is built only from empty cells.
+func isEmptyCSVValue(value interface{}) bool {
+	switch v := value.(type) {
+	case csvRawValue:
+		list, isList := v.value.([]interface{})
+		return v.value == nil || (isList && len(list) == 0)
+	case map[string]interface{}:
+		for _, childValue := range v {
+			if !isEmptyCSVValue(childValue) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		return len(v) == 0
+	}
+	return value == nil || value == 0 || value == "" || value == false
+}
+
+func unwrapCSVRawValues(value interface{}) interface{} {
+	switch v := value.(type) {
+	case csvRawValue:
+		return v.value
+	case map[string]interface{}:
+		for key, childValue := range v {
+			v[key] = unwrapCSVRawValues(childValue)
+		}
+	case []interface{}:
+		for i, childValue := range v {
+			v[i] = unwrapCSVRawValues(childValue)
+		}
+	}
+	return value
+}
+
+func splitListCell(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, listSeparator) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// lookupPath returns the value of the dotted path, e.g. "items.0.name", in the record.
+func lookupPath(value interface{}, path string) (interface{}, bool) {
+	for _, key := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if value, ok = v[key]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, false
+			}
+			value = v[index]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func isArrayIndex(key string) bool {
+	index, err := strconv.Atoi(key)
+	return err == nil && index >= 0
+}
@@ -1,7 +1,13 @@
 package main
 
 import (
+	"encoding/json"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	. "github.com/smartystreets/goconvey/convey"
 )
@@ -17,13 +23,35 @@ func TestCSV(t *testing.T) {
 					[]string{"baz", "3", "3", ""},
 				}
 
-				actual, err := newCSVColumns(csvRecords)
+				actual, err := newCSVColumns(csvRecords, &CSVTableOptions{})
 				So(err, ShouldBeNil)
 				So(actual, ShouldResemble, []*CSVColumn{
 					&CSVColumn{index: 0, name: "str", isString: true, isBool: false},
-					&CSVColumn{index: 1, name: "num", isString: false, isBool: false},
-					&CSVColumn{index: 2, name: "mixed", isString: true, isBool: false},
-					&CSVColumn{index: 3, name: "bool", isString: false, isBool: true},
+					&CSVColumn{index: 1, name: "num", isString: false, isBool: false, hasNumbers: true},
+					&CSVColumn{index: 2, name: "mixed", isString: true, isBool: false, hasNumbers: true},
+					&CSVColumn{index: 3, name: "bool", isString: false, isBool: true, hasLiterals: true, hasTextLiterals: true},
+				})
+			})
+
+			Convey("with conflicting column names", func() {
+				Convey("should return an error which describes the conflict", func() {
+					for header, message := range map[string]string{
+						"a,a.b":                  "Column a.b conflicts with a: a has a value, so it cannot have nested keys",
+						"a.b,a":                  "Column a conflicts with a.b: a has nested keys, so it cannot have a value",
+						"items.0,items.0.name":   "Column items.0.name conflicts with items.0: items.0 has a value, so it cannot have nested keys",
+						"items.0.name,items.foo": "Column items.foo conflicts with items.0.name: items is used as both an array and an object",
+						"a.0.0,a.0.b":            "Column a.0.b conflicts with a.0.0: a.0 is used as both an array and an object",
+						"a.*.b,a.0.c":            "Column a.0.c conflicts with a.*.b: a is used as both a multi-row array and an array",
+						"id,a.*.b.*.c":           "Column a.*.b.*.c has nested multi-row arrays",
+						"a.*.b,id":               "Column a.*.b is the key of multi-row records, so it cannot be in a multi-row array",
+						"a,a":                    "Column a is duplicated",
+						"a.0,a.00":               "Column a.00 is duplicated",
+						"a.65536":                "Column a.65536 has too large array index: 65536 (maximum is 65535)",
+					} {
+						_, err := newCSVColumns([][]string{strings.Split(header, ","), strings.Split(header, ",")}, &CSVTableOptions{})
+						So(err, ShouldNotBeNil)
+						So(err.Error(), ShouldEqual, message)
+					}
 				})
 			})
 		})
@@ -40,13 +68,16 @@ func TestCSV(t *testing.T) {
 						encoding: "utf-8",
 						columns: []*CSVColumn{
 							&CSVColumn{index: 0, name: "str", isString: true, isBool: false},
-							&CSVColumn{index: 1, name: "num", isString: false, isBool: false},
-							&CSVColumn{index: 2, name: "bool", isString: false, isBool: true},
+							&CSVColumn{index: 1, name: "num", isString: false, isBool: false, hasNumbers: true},
+							&CSVColumn{index: 2, name: "bool", isString: false, isBool: true, hasLiterals: true, hasTextLiterals: true},
 						},
 						rows: [][]interface{}{
 							[]interface{}{"foo", 1.0, true},
 							[]interface{}{"bar", 2.0, false},
 						},
+						keyCells: []string{"foo", "bar"},
+						lines:    []csvLine{{number: 2}, {number: 3}},
+						options:  &CSVTableOptions{location: time.UTC},
 					})
 				})
 			})
@@ -71,6 +102,16 @@ func TestCSV(t *testing.T) {
 				})
 			})
 
+			Convey("with conflicting column names", func() {
+				csvData := []byte("items.0,items.0.name\na,b")
+
+				Convey("should return a error", func() {
+					actual, err := newCSVTable("test.csv", "utf-8", csvData)
+					So(err, ShouldNotBeNil)
+					So(actual, ShouldBeNil)
+				})
+			})
+
 			Convey("with bumpy data", func() {
 				csvData := []byte("str,num\na")
 
@@ -80,6 +121,212 @@ func TestCSV(t *testing.T) {
 					So(actual, ShouldBeNil)
 				})
 			})
+
+			Convey("with unknown column type", func() {
+				csvData := []byte("id,start_at:date\n1,2026/10/01")
+
+				Convey("should return a error", func() {
+					actual, err := newCSVTable("test.csv", "utf-8", csvData)
+					So(err.Error(), ShouldEqual, "Unknown kind of column start_at: date")
+					So(actual, ShouldBeNil)
+				})
+			})
+		})
+
+		Convey(".newCSVTableWithOptions", func() {
+			Convey("with enum column", func() {
+				options := &CSVTableOptions{enums: map[string]*Enum{
+					"Rarity": &Enum{name: "Rarity", values: map[string]int{"R": 1, "SR": 2, "SSR": 3}},
+				}}
+
+				Convey("should convert labels to the values", func() {
+					csvTable, err := newCSVTableWithOptions("test.csv", "utf-8", []byte("id,rarity:Rarity\n1,SSR\n2,"), options)
+					So(err, ShouldBeNil)
+					So(csvTable.rows, ShouldResemble, [][]interface{}{
+						[]interface{}{1.0, 3.0},
+						[]interface{}{2.0, nil},
+					})
+					So(csvTable.enums(), ShouldResemble, options.enums)
+				})
+
+				Convey("should return a error with unknown label", func() {
+					_, err := newCSVTableWithOptions("test.csv", "utf-8", []byte("id,rarity:Rarity\n1,SSR\n2,UR"), options)
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldEqual,
+						"Invalid enum in line 3, column rarity: Unknown label of Rarity: UR (expected R, SR, SSR)")
+				})
+			})
+
+			csvData := []byte("id,start_at:datetime\n1,2026/10/01 12:00\n2,2026-10-1\n3,2026-10-01T12:00:00+09:00\n4,")
+			options := &CSVTableOptions{location: time.FixedZone("JST", 9*60*60)}
+
+			Convey("with datetime column", func() {
+				Convey("should convert datetimes to RFC 3339 strings in UTC", func() {
+					csvTable, err := newCSVTableWithOptions("test.csv", "utf-8", csvData, options)
+					So(err, ShouldBeNil)
+					So(csvTable.columns[1], ShouldResemble, &CSVColumn{index: 1, name: "start_at", kind: "datetime"})
+					So(csvTable.rows, ShouldResemble, [][]interface{}{
+						[]interface{}{1.0, "2026-10-01T03:00:00Z"},
+						[]interface{}{2.0, "2026-09-30T15:00:00Z"},
+						[]interface{}{3.0, "2026-10-01T03:00:00Z"},
+						[]interface{}{4.0, nil},
+					})
+					So(csvTable.columnSchemas(), ShouldResemble, map[string]map[string]interface{}{
+						"start_at": map[string]interface{}{"type": "string", "format": "date-time"},
+					})
+				})
+
+				Convey("should convert datetimes to Unix timestamps with unix format", func() {
+					options.datetimeFormat = unixDatetimeFormat
+					csvTable, err := newCSVTableWithOptions("test.csv", "utf-8", csvData, options)
+					So(err, ShouldBeNil)
+					So(csvTable.rows[0], ShouldResemble, []interface{}{1.0, 1790823600.0})
+				})
+
+				Convey("should return a error with invalid datetime", func() {
+					csvData := []byte("id,start_at:datetime\n1,2026/10/01\n2,next monday")
+					_, err := newCSVTableWithOptions("test.csv", "utf-8", csvData, options)
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldEqual, "Invalid datetime in line 3, column start_at: next monday")
+				})
+			})
+
+			Convey("with ignored columns", func() {
+				options := &CSVTableOptions{ignoreColumns: []string{"memo*"}}
+
+				Convey("should remove the columns which match the patterns", func() {
+					csvTable, err := newCSVTableWithOptions("test.csv", "utf-8", []byte("id,memo,name,memo.2\n1,x,a,y"), options)
+					So(err, ShouldBeNil)
+					So(csvTable.columns, ShouldResemble, []*CSVColumn{
+						&CSVColumn{index: 0, name: "id", hasNumbers: true},
+						&CSVColumn{index: 1, name: "name", isString: true},
+					})
+					So(csvTable.rows, ShouldResemble, [][]interface{}{[]interface{}{1.0, "a"}})
+				})
+
+				Convey("should remove the columns whose names without the kinds match the patterns", func() {
+					options := &CSVTableOptions{ignoreColumns: []string{"note"}}
+					csvTable, err := newCSVTableWithOptions("test.csv", "utf-8", []byte("id,note:json\n1,{}"), options)
+					So(err, ShouldBeNil)
+					So(csvTable.columns, ShouldResemble, []*CSVColumn{&CSVColumn{index: 0, name: "id", hasNumbers: true}})
+				})
+
+				Convey("should return a error if all columns are ignored", func() {
+					_, err := newCSVTableWithOptions("test.csv", "utf-8", []byte("memo\nx"), options)
+					So(err, ShouldNotBeNil)
+				})
+			})
+
+			Convey("with comment columns and disabled rows", func() {
+				csvData := []byte("#,id,name,// memo,#note\n,1,Sword,old,x\n#,2,Shield,,\n# TODO,3,TBD,,\n,4,Bow,,")
+
+				Convey("should remove the comment columns and the disabled rows", func() {
+					csvTable, err := newCSVTableWithOptions("test.csv", "utf-8", csvData, &CSVTableOptions{})
+					So(err, ShouldBeNil)
+					So(csvTable.columns, ShouldResemble, []*CSVColumn{
+						&CSVColumn{index: 0, name: "id", hasNumbers: true},
+						&CSVColumn{index: 1, name: "name", isString: true},
+					})
+					So(csvTable.rows, ShouldResemble, [][]interface{}{
+						[]interface{}{1.0, "Sword"},
+						[]interface{}{4.0, "Bow"},
+					})
+				})
+
+				Convey("should return a error with the line number in the CSV data", func() {
+					csvData := []byte("id,rarity:Rarity\n#1,UR\n2,UR")
+					options := &CSVTableOptions{enums: map[string]*Enum{
+						"Rarity": &Enum{name: "Rarity", values: map[string]int{"R": 1}},
+					}}
+					_, err := newCSVTableWithOptions("test.csv", "utf-8", csvData, options)
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldStartWith, "Invalid enum in line 3, ")
+				})
+
+				Convey("should return a error of a number out of range with the line number", func() {
+					csvData := []byte("id,count\n#1,1\n2,1" + strings.Repeat("0", 400))
+					_, err := newCSVTable("test.csv", "utf-8", csvData)
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldStartWith, "Invalid value in line 3, column count: Not a number: 10")
+				})
+			})
+
+			Convey("with boolean literals", func() {
+				csvData := []byte("id,is_rare,is_sold,in_stock\n1,1,○,true\n2,0,×,False\n3,,,\n")
+				options := &CSVTableOptions{
+					booleans:       &BooleanLiterals{TrueValues: []string{"○", "TRUE"}, FalseValues: []string{"×", "FALSE"}},
+					columnBooleans: map[string]*BooleanLiterals{"is_rare": &BooleanLiterals{TrueValues: []string{"1"}, FalseValues: []string{"0"}}},
+				}
+
+				Convey("should parse the literals of the project and the column as booleans", func() {
+					csvTable, err := newCSVTableWithOptions("test.csv", "utf-8", csvData, options)
+					So(err, ShouldBeNil)
+					So(csvTable.rows, ShouldResemble, [][]interface{}{
+						[]interface{}{1.0, true, true, true},
+						[]interface{}{2.0, false, false, false},
+						[]interface{}{3.0, false, false, false},
+					})
+				})
+
+				Convey("should not parse the literals of the other columns as booleans", func() {
+					csvTable, err := newCSVTable("test.csv", "utf-8", csvData)
+					So(err, ShouldBeNil)
+					So(csvTable.rows[0], ShouldResemble, []interface{}{1.0, 1.0, "○", true})
+				})
+			})
+
+			Convey("with numeric boolean literals of the project", func() {
+				options := &CSVTableOptions{
+					booleans:       &BooleanLiterals{TrueValues: []string{"TRUE", "1"}, FalseValues: []string{"FALSE", "0"}},
+					columnBooleans: map[string]*BooleanLiterals{"is_rare": &BooleanLiterals{TrueValues: []string{"1"}, FalseValues: []string{"0"}}},
+				}
+
+				Convey("should not parse the numbers as booleans in the columns which are not given them", func() {
+					csvData := []byte("id,is_rare,is_sold\n1,1,TRUE\n0,0,false\n")
+					csvTable, err := newCSVTableWithOptions("test.csv", "utf-8", csvData, options)
+					So(err, ShouldBeNil)
+					So(csvTable.rows, ShouldResemble, [][]interface{}{
+						[]interface{}{1.0, true, true},
+						[]interface{}{0.0, false, false},
+					})
+				})
+			})
+
+			Convey("with boolean literals and numbers in a column", func() {
+				options := &CSVTableOptions{
+					columnBooleans: map[string]*BooleanLiterals{"rank": &BooleanLiterals{TrueValues: []string{"1"}, FalseValues: []string{"0"}}},
+				}
+
+				Convey("should be a string column if the literals are not numbers", func() {
+					csvTable, err := newCSVTable("test.csv", "utf-8", []byte("id,value\n1,TRUE\n2,5\n3,\n"))
+					So(err, ShouldBeNil)
+					So(csvTable.rows, ShouldResemble, [][]interface{}{
+						[]interface{}{1.0, "TRUE"},
+						[]interface{}{2.0, "5"},
+						[]interface{}{3.0, ""},
+					})
+				})
+
+				Convey("should be a number column if the literals are numbers", func() {
+					csvData := []byte("id,rank\n1,1\n2,5\n3,0\n")
+					csvTable, err := newCSVTableWithOptions("test.csv", "utf-8", csvData, options)
+					So(err, ShouldBeNil)
+					So(csvTable.rows, ShouldResemble, [][]interface{}{
+						[]interface{}{1.0, 1.0},
+						[]interface{}{2.0, 5.0},
+						[]interface{}{3.0, 0.0},
+					})
+				})
+			})
+
+			Convey("with unknown key column", func() {
+				Convey("should return a error", func() {
+					options := &CSVTableOptions{keyColumn: "code"}
+					_, err := newCSVTableWithOptions("test.csv", "utf-8", []byte("id,name\n1,a"), options)
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldEqual, "Key column is not found: code")
+				})
+			})
 		})
 	})
 }
@@ -97,12 +344,178 @@ func TestCSVColumn(t *testing.T) {
 				So(column.validate(), ShouldNotBeNil)
 			})
 		})
+
+		Convey("#scalarValue", func() {
+			Convey("with boolean column", func() {
+				column := &CSVColumn{name: "flag", isBool: true}
+
+				Convey("should return false for an empty cell", func() {
+					actual, err := column.scalarValue("", defaultBooleanLiterals)
+					So(err, ShouldBeNil)
+					So(actual, ShouldEqual, false)
+				})
+
+				Convey("should return error for a value which is not a literal", func() {
+					_, err := column.scalarValue("yes", defaultBooleanLiterals)
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldEqual, "Not a boolean literal: yes")
+				})
+			})
+		})
 	})
 }
 
 func TestCSVTable(t *testing.T) {
 	Convey("CSVTable", t, func() {
 		Convey("#data", func() {
+			Convey("with list and json columns", func() {
+				csvData := []byte("id,tags:list,counts:list,items.0.data:json,items.0.tags:list\n" +
+					"1,a|b| c,1|2,\"{\"\"x\"\":[\"\"\"\",null]}\",x\n" +
+					"2,,3,,\n")
+				csvTable, err := newCSVTable("test.csv", "utf-8", csvData)
+				So(err, ShouldBeNil)
+
+				Convey("should return map data which has arrays and objects in cells", func() {
+					actual, err := csvTable.data()
+					So(err, ShouldBeNil)
+					So(actual, ShouldResemble, []map[string]interface{}{
+						map[string]interface{}{
+							"id":     1.0,
+							"tags":   []interface{}{"a", "b", "c"},
+							"counts": []interface{}{1.0, 2.0},
+							"items": []interface{}{
+								map[string]interface{}{
+									"data": map[string]interface{}{"x": []interface{}{"", nil}},
+									"tags": []interface{}{"x"},
+								},
+							},
+						},
+						map[string]interface{}{
+							"id":     2.0,
+							"tags":   []interface{}{},
+							"counts": []interface{}{3.0},
+							"items":  []interface{}{},
+						},
+					})
+				})
+			})
+
+			Convey("with multi-row columns", func() {
+				csvData := []byte("id,name,steps.*.text,steps.*.wait\n" +
+					"1,first,hello,1\n" +
+					",,world,2\n" +
+					"2,second,,\n" +
+					",,bye,\n")
+				csvTable, err := newCSVTable("test.csv", "utf-8", csvData)
+				So(err, ShouldBeNil)
+
+				Convey("should append the values of the continued rows to the array", func() {
+					actual, err := csvTable.data()
+					So(err, ShouldBeNil)
+					So(actual, ShouldResemble, []map[string]interface{}{
+						map[string]interface{}{
+							"id":   1.0,
+							"name": "first",
+							"steps": []interface{}{
+								map[string]interface{}{"text": "hello", "wait": 1.0},
+								map[string]interface{}{"text": "world", "wait": 2.0},
+							},
+						},
+						map[string]interface{}{
+							"id":   2.0,
+							"name": "second",
+							"steps": []interface{}{
+								map[string]interface{}{"text": "bye", "wait": 0},
+							},
+						},
+					})
+				})
+			})
+
+			Convey("with multi-row columns and key column", func() {
+				csvData := []byte("steps.*.text,id\nhello,a\nworld,\nbye,b\n")
+				options := &CSVTableOptions{keyColumn: "id", sortBy: "id", sortDescending: true}
+				csvTable, err := newCSVTableWithOptions("test.csv", "utf-8", csvData, options)
+				So(err, ShouldBeNil)
+
+				Convey("should continue the previous record if the key column is empty", func() {
+					actual, err := csvTable.data()
+					So(err, ShouldBeNil)
+					So(actual, ShouldResemble, []map[string]interface{}{
+						map[string]interface{}{
+							"id":    "b",
+							"steps": []interface{}{map[string]interface{}{"text": "bye"}},
+						},
+						map[string]interface{}{
+							"id": "a",
+							"steps": []interface{}{
+								map[string]interface{}{"text": "hello"},
+								map[string]interface{}{"text": "world"},
+							},
+						},
+					})
+				})
+			})
+
+			Convey("with multi-row columns and false key", func() {
+				csvData := []byte("id,steps.*.text\nTRUE,hello\nFALSE,bye\n,world\n")
+				csvTable, err := newCSVTable("test.csv", "utf-8", csvData)
+				So(err, ShouldBeNil)
+
+				Convey("should start a new record if the key column is not empty", func() {
+					actual, err := csvTable.data()
+					So(err, ShouldBeNil)
+					So(actual, ShouldResemble, []map[string]interface{}{
+						map[string]interface{}{"id": true, "steps": []interface{}{map[string]interface{}{"text": "hello"}}},
+						map[string]interface{}{
+							"id": false,
+							"steps": []interface{}{
+								map[string]interface{}{"text": "bye"},
+								map[string]interface{}{"text": "world"},
+							},
+						},
+					})
+				})
+			})
+
+			Convey("with sort column", func() {
+				csvData := []byte("id,score\n1,10\n2,\n3,2\n4,10\n")
+				csvTable, err := newCSVTableWithOptions("test.csv", "utf-8", csvData, &CSVTableOptions{sortBy: "score"})
+				So(err, ShouldBeNil)
+
+				Convey("should return the records in ascending order of the column", func() {
+					actual, err := csvTable.data()
+					So(err, ShouldBeNil)
+					var ids []interface{}
+					for _, record := range actual {
+						ids = append(ids, record["id"])
+					}
+					So(ids, ShouldResemble, []interface{}{2.0, 3.0, 1.0, 4.0})
+				})
+			})
+
+			Convey("with multi-row columns and a value in the continued row", func() {
+				csvData := []byte("id,name,steps.*.text\n1,first,hello\n,oops,world")
+				csvTable, _ := newCSVTable("test.csv", "utf-8", csvData)
+
+				Convey("should return a error", func() {
+					_, err := csvTable.data()
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldEqual,
+						"Column name should be empty in line 3, because the row continues the previous record")
+				})
+			})
+
+			Convey("with invalid JSON cell", func() {
+				csvData := []byte("id,data:json\n1,{x}")
+
+				Convey("should return a error", func() {
+					_, err := newCSVTable("test.csv", "utf-8", csvData)
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldStartWith, "Invalid JSON in line 2, column data: ")
+				})
+			})
+
 			Convey("with normal key-value data", func() {
 				csvData := []byte("str,num,bool\nfoo,1,TRUE\nbar,2,FALSE")
 				csvTable, _ := newCSVTable("test.csv", "utf-8", csvData)
@@ -225,6 +638,276 @@ func TestCSVTable(t *testing.T) {
 					})
 				})
 			})
+
+			Convey("with nested column name which is minus number", func() {
+				csvData := []byte("items.0.-1,items.0.-2\na,b")
+				csvTable, _ := newCSVTable("test.csv", "utf-8", csvData)
+
+				Convey("should return map data", func() {
+					actual, err := csvTable.data()
+					So(err, ShouldBeNil)
+					So(actual, ShouldResemble, []map[string]interface{}{
+						map[string]interface{}{"items": []interface{}{map[string]interface{}{"-1": "a", "-2": "b"}}},
+					})
+				})
+			})
 		})
 	})
 }
+
+func FuzzCSVTableRoundTrip(f *testing.F) {
+	f.Add("id,name,age,gender,items.0.name,items.0.count,items.2.name,items.2.sale,parents.father,voice_actors.0", int64(0))
+	f.Add("items.0.0,items.0.2,items.1.0", int64(1))
+	f.Add("obj.items.0.tags.0,obj.items.0.tags.1,obj.items.1.name", int64(2))
+	f.Add("a,a.0", int64(3))
+	f.Add("items.0.name,items.foo", int64(4))
+	f.Add("a.0.-1,a.0.0", int64(5))
+	f.Add("a.00,a.0.00", int64(6))
+	f.Add("a.0.0,a.04444444444.1", int64(7))
+	f.Add("a.0.A.0.A,a.0.B,A.000,B.000", int64(5055700485549117703))
+	f.Add("id,steps.*.text,steps.*.count", int64(8))
+	f.Add("id,tags.*,items.*.ids.0,items.*.ids.1", int64(9))
+	f.Add("a,#memo,// note,b.0", int64(10))
+	f.Add("#x,a", int64(11))
+	random := rand.New(rand.NewSource(0))
+	for i := 0; i < 100; i++ {
+		f.Add(strings.Join(randomCSVHeader(random), ","), random.Int63())
+	}
+
+	f.Fuzz(func(t *testing.T, header string, seed int64) {
+		random := rand.New(rand.NewSource(seed))
+		csvData := randomCSVData(random, strings.Split(header, ","), random.Intn(5)+1)
+		csvTable, err := newCSVTable("test.csv", "utf-8", csvData)
+		if err != nil {
+			t.Skip()
+		}
+		// Random rows may have values in the continued rows of multi-row records, which is invalid data.
+		hasMultiRowColumns := false
+		for _, column := range csvTable.columns {
+			hasMultiRowColumns = hasMultiRowColumns || column.isMultiRow()
+		}
+		if _, err := csvTable.data(); hasMultiRowColumns && err != nil {
+			t.Skip()
+		}
+
+		data := csvTableData(t, csvTable, header)
+		if !hasUniformArrayItems(strings.Split(header, ",")) {
+			return
+		}
+		records := make([]interface{}, len(data))
+		for i, record := range data {
+			records[i] = record
+		}
+		flattened, err := flattenRecords(records)
+		if err != nil && strings.Contains(err.Error(), "because it disables the row") {
+			// The first column of the exported CSV data may differ from the original one, and its value may start with "#".
+			return
+		} else if err != nil {
+			t.Fatalf("Failed to flatten records of %v: %v", header, err)
+		}
+		if len(flattened[0]) == 0 {
+			return
+		}
+
+		flattenedData, _ := writeCSVRecords(flattened)
+		roundTrippedTable, err := newCSVTable("test.csv", "utf-8", flattenedData)
+		if err != nil {
+			t.Fatalf("Failed to parse flattened CSV of %v: %v\n%s", header, err, flattenedData)
+		}
+		roundTripped := csvTableData(t, roundTrippedTable, header)
+
+		expected, actual := normalizeJSON(data), normalizeJSON(roundTripped)
+		if !reflect.DeepEqual(expected, actual) {
+			t.Fatalf("Round trip is not lossless: %v\n%s\nexpected: %v\nactual:   %v", header, csvData, expected, actual)
+		}
+	})
+}
+
+func csvTableData(t *testing.T, csvTable *CSVTable, header string) (data []map[string]interface{}) {
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("Panicked while converting %v: %v", header, r)
+		}
+	}()
+	data, err := csvTable.data()
+	if err != nil {
+		t.Fatalf("Failed to convert %v: %v", header, err)
+	}
+	return data
+}
+
+// hasUniformArrayItems reports whether every item of each array has the same columns.
+// Empty array items are removed while converting, so the round trip is lossless only in that case.
+func hasUniformArrayItems(header []string) bool {
+	var dataHeader []string
+	for _, columnName := range header {
+		if !isCommentCSVHeader(columnName) {
+			dataHeader = append(dataHeader, columnName)
+		}
+	}
+	header = dataHeader
+
+	columnNames := make(map[string]bool)
+	indexes := make(map[string]map[string]bool)
+	for _, columnName := range header {
+		columnNames[columnName] = true
+		keys := strings.Split(columnName, ".")
+		for i, key := range keys {
+			if isArrayIndex(key) {
+				prefix := strings.Join(keys[:i], ".")
+				if indexes[prefix] == nil {
+					indexes[prefix] = make(map[string]bool)
+				}
+				indexes[prefix][key] = true
+			}
+		}
+	}
+
+	for _, columnName := range header {
+		keys := strings.Split(columnName, ".")
+		for i, key := range keys {
+			if !isArrayIndex(key) {
+				continue
+			}
+			for index := range indexes[strings.Join(keys[:i], ".")] {
+				replaced := append(append(append([]string{}, keys[:i]...), index), keys[i+1:]...)
+				if !columnNames[strings.Join(replaced, ".")] {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+func randomCSVHeader(random *rand.Rand) []string {
+	var shape func(depth int, isRoot bool) []string
+	shape = func(depth int, isRoot bool) []string {
+		var keys, suffixes []string
+		switch n := random.Intn(3); {
+		case !isRoot && (depth >= 3 || n == 0):
+			return []string{""}
+		case !isRoot && n == 1 && random.Intn(4) == 0:
+			keys = []string{multiRowArrayKey}
+			suffixes = shape(depth+1, false)
+		case !isRoot && n == 1:
+			for i := 0; i < random.Intn(4)+1; i++ {
+				if random.Intn(4) != 0 {
+					keys = append(keys, strconv.Itoa(i))
+				}
+			}
+			suffixes = shape(depth+1, false)
+		default:
+			for i := 0; i < random.Intn(4)+1; i++ {
+				keys = append(keys, string(rune('a'+i)))
+			}
+		}
+
+		var result []string
+		for _, key := range keys {
+			if suffixes == nil || !(isArrayIndex(key) || key == multiRowArrayKey) {
+				suffixes = shape(depth+1, false)
+			}
+			for _, suffix := range suffixes {
+				result = append(result, joinColumnPath(key, suffix))
+			}
+		}
+		return result
+	}
+
+	for {
+		header := shape(0, true)
+		if len(header) == 0 {
+			continue
+		}
+		// Comment columns are dropped while converting.
+		for i := range header {
+			if random.Intn(8) == 0 {
+				header[i] = []string{"#", "//"}[random.Intn(2)] + header[i]
+			}
+		}
+		return header
+	}
+}
+
+func randomCSVData(random *rand.Rand, header []string, rowLength int) []byte {
+	records := [][]string{header}
+	kinds := make(map[string]int)
+	hasMultiRowColumns := false
+	for _, columnName := range header {
+		hasMultiRowColumns = hasMultiRowColumns || (&CSVColumn{name: columnName}).isMultiRow()
+	}
+	for i := 0; i < rowLength; i++ {
+		record := make([]string, len(header))
+		// The continued rows of multi-row records have values only in the multi-row columns,
+		// and the other rows have the key, which is the first column.
+		isContinued := i > 0 && hasMultiRowColumns && random.Intn(2) == 0
+		for j, columnName := range header {
+			// Use the same value type for the same column in every array item.
+			var keys []string
+			for _, key := range strings.Split(columnName, ".") {
+				if !isArrayIndex(key) {
+					keys = append(keys, key)
+				}
+			}
+			logicalName := strings.Join(keys, ".")
+			if _, ok := kinds[logicalName]; !ok {
+				kinds[logicalName] = random.Intn(3)
+			}
+			if isContinued && !(&CSVColumn{name: columnName}).isMultiRow() {
+				continue
+			} else if i > 0 && random.Intn(4) == 0 && !(hasMultiRowColumns && j == 0) {
+				continue
+			}
+
+			switch kinds[logicalName] {
+			case 0:
+				record[j] = strconv.FormatFloat(float64(random.Intn(10000)+1)/100, 'f', -1, 64)
+			case 1:
+				record[j] = []string{"TRUE", "FALSE"}[random.Intn(2)]
+			default:
+				// The rows whose first cell starts with "#" are disabled.
+				record[j] = []string{"foo", "bar", "ムーミン", "a b", "x,y", "#x", "//y"}[random.Intn(7)]
+			}
+		}
+		records = append(records, record)
+	}
+
+	csvData, _ := writeCSVRecords(records)
+	return csvData
+}
+
+// normalizeJSON drops empty arrays and objects because they have no columns to be flattened into.
+func normalizeJSON(data []map[string]interface{}) interface{} {
+	var result interface{}
+	jsonData, _ := json.Marshal(data)
+	json.Unmarshal(jsonData, &result)
+
+	var normalize func(value interface{}) (interface{}, bool)
+	normalize = func(value interface{}) (interface{}, bool) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			for key, childValue := range v {
+				if normalized, isEmpty := normalize(childValue); isEmpty {
+					delete(v, key)
+				} else {
+					v[key] = normalized
+				}
+			}
+			return v, len(v) == 0
+		case []interface{}:
+			array := []interface{}{}
+			for _, childValue := range v {
+				if normalized, isEmpty := normalize(childValue); !isEmpty {
+					array = append(array, normalized)
+				}
+			}
+			return array, len(array) == 0
+		}
+		return value, false
+	}
+	for _, record := range result.([]interface{}) {
+		normalize(record)
+	}
+	return result
+}
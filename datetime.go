@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// DatetimeFormat represents how values of datetime columns are output.
+type DatetimeFormat int
+
+const (
+	rfc3339DatetimeFormat DatetimeFormat = iota
+	unixDatetimeFormat
+)
+
+var datetimeFormatNames = map[string]DatetimeFormat{
+	"rfc3339": rfc3339DatetimeFormat,
+	"unix":    unixDatetimeFormat,
+}
+
+// datetimeLayouts are the formats of datetime cells which are commonly written in spreadsheets.
+// Values without time zone are interpreted in the source time zone.
+var datetimeLayouts = []string{
+	"2006/1/2 15:04:05",
+	"2006/1/2 15:04",
+	"2006/1/2",
+	"2006-1-2 15:04:05",
+	"2006-1-2 15:04",
+	"2006-1-2",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+}
+
+func parseDatetimeFormat(value string) (DatetimeFormat, error) {
+	format, ok := datetimeFormatNames[value]
+	if !ok {
+		return rfc3339DatetimeFormat, fmt.Errorf("Unknown datetime format: %v", value)
+	}
+	return format, nil
+}
+
+func parseDatetime(value string, location *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range datetimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, location); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("Unsupported datetime format: %v", value)
+}
+
+// value returns the JSON value of the time, which is an RFC 3339 string in UTC or a Unix timestamp.
+func (f DatetimeFormat) value(t time.Time) interface{} {
+	if f == unixDatetimeFormat {
+		return float64(t.Unix())
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// schema returns the JSON Schema keywords of the values.
+func (f DatetimeFormat) schema() map[string]interface{} {
+	if f == unixDatetimeFormat {
+		return map[string]interface{}{"type": "integer"}
+	}
+	return map[string]interface{}{"type": "string", "format": "date-time"}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+func TestDatetime(t *testing.T) {
+	Convey("datetime", t, func() {
+		jst := time.FixedZone("JST", 9*60*60)
+
+		Convey(".parseDatetime", func() {
+			Convey("should parse datetimes in the location", func() {
+				expected := time.Date(2026, 10, 1, 9, 5, 0, 0, jst)
+				for _, value := range []string{"2026/10/01 09:05", "2026/10/1 9:05", "2026-10-01 09:05:00", "2026-10-01T09:05"} {
+					actual, err := parseDatetime(value, jst)
+					So(err, ShouldBeNil)
+					So(actual.Equal(expected), ShouldBeTrue)
+				}
+			})
+
+			Convey("should prefer the time zone of RFC 3339 datetimes", func() {
+				actual, err := parseDatetime("2026-10-01T00:00:00Z", jst)
+				So(err, ShouldBeNil)
+				So(actual.Equal(time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)), ShouldBeTrue)
+			})
+
+			Convey("should return error with unsupported format", func() {
+				_, err := parseDatetime("10/01/2026", jst)
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey(".parseDatetimeFormat", func() {
+			Convey("should return DatetimeFormat", func() {
+				format, err := parseDatetimeFormat("unix")
+				So(err, ShouldBeNil)
+				So(format, ShouldEqual, unixDatetimeFormat)
+
+				_, err = parseDatetimeFormat("iso")
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// TableDiff represents the changes of the records in a table, which are identified by the key column.
+type TableDiff struct {
+	Table   string        `json:"table"`
+	Added   []string      `json:"added"`
+	Removed []string      `json:"removed"`
+	Changed []*RecordDiff `json:"changed"`
+}
+
+// RecordDiff represents the changes of the fields in a record.
+type RecordDiff struct {
+	Key     string         `json:"key"`
+	Changes []*FieldChange `json:"changes"`
+}
+
+// FieldChange represents the change of a field, whose path is a column name, e.g. "items.0.count".
+// Old or New is nil if the field is added or removed.
+type FieldChange struct {
+	Path string  `json:"path"`
+	Old  *string `json:"old"`
+	New  *string `json:"new"`
+}
+
+// diffMasterDataList returns the changes of the tables which are matched by the table names.
+// The tables which have no changes are omitted.
+func diffMasterDataList(oldList []*MasterData, newList []*MasterData) ([]*TableDiff, error) {
+	oldTables := make(map[string]*MasterData)
+	newTables := make(map[string]*MasterData)
+	var tableNames []string
+	for _, masterData := range oldList {
+		oldTables[masterData.tableName()] = masterData
+		tableNames = append(tableNames, masterData.tableName())
+	}
+	for _, masterData := range newList {
+		newTables[masterData.tableName()] = masterData
+		if oldTables[masterData.tableName()] == nil {
+			tableNames = append(tableNames, masterData.tableName())
+		}
+	}
+	sort.Strings(tableNames)
+
+	var result []*TableDiff
+	for _, tableName := range tableNames {
+		oldKeys, oldRecords, err := keyedRecords(oldTables[tableName])
+		if err != nil {
+			return nil, err
+		}
+		newKeys, newRecords, err := keyedRecords(newTables[tableName])
+		if err != nil {
+			return nil, err
+		}
+
+		tableDiff := &TableDiff{Table: tableName, Added: []string{}, Removed: []string{}, Changed: []*RecordDiff{}}
+		for _, key := range oldKeys {
+			if _, ok := newRecords[key]; !ok {
+				tableDiff.Removed = append(tableDiff.Removed, key)
+			}
+		}
+		for _, key := range newKeys {
+			oldRecord, ok := oldRecords[key]
+			if !ok {
+				tableDiff.Added = append(tableDiff.Added, key)
+			} else if changes := diffRecords(oldRecord, newRecords[key]); len(changes) > 0 {
+				tableDiff.Changed = append(tableDiff.Changed, &RecordDiff{Key: key, Changes: changes})
+			}
+		}
+
+		if len(tableDiff.Added)+len(tableDiff.Removed)+len(tableDiff.Changed) > 0 {
+			result = append(result, tableDiff)
+		}
+	}
+	return result, nil
+}
+
+// keyedRecords returns the values of the key column in order and the records by them.
+// It returns no records if the master data is nil.
+func keyedRecords(masterData *MasterData) ([]string, map[string]interface{}, error) {
+	records := make(map[string]interface{})
+	if masterData == nil {
+		return nil, records, nil
+	}
+
+	var keys []string
+	for i, record := range masterData.records() {
+		key, ok := lookupPath(record, masterData.keyColumn)
+		if !ok {
+			return nil, nil, fmt.Errorf("Record %v of %v has no key: %v", i, masterData.fileName, masterData.keyColumn)
+		}
+		keyText := fmt.Sprint(key)
+		if _, ok := records[keyText]; ok {
+			return nil, nil, fmt.Errorf("Key of %v is duplicated: %v %v", masterData.fileName, masterData.keyColumn, keyText)
+		}
+		keys = append(keys, keyText)
+		records[keyText] = record
+	}
+	return keys, records, nil
+}
+
+// diffRecords returns the changes of the flattened fields in order of the column names.
+func diffRecords(oldRecord interface{}, newRecord interface{}) []*FieldChange {
+	oldFields := make(map[string]string)
+	newFields := make(map[string]string)
+	flattenValue(oldFields, "", oldRecord)
+	flattenValue(newFields, "", newRecord)
+
+	var paths []string
+	for path, oldValue := range oldFields {
+		if newValue, ok := newFields[path]; !ok || newValue != oldValue {
+			paths = append(paths, path)
+		}
+	}
+	for path := range newFields {
+		if _, ok := oldFields[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		return lessColumnPath(paths[i], paths[j])
+	})
+
+	changes := make([]*FieldChange, len(paths))
+	for i, path := range paths {
+		changes[i] = &FieldChange{Path: path}
+		if oldValue, ok := oldFields[path]; ok {
+			changes[i].Old = &oldValue
+		}
+		if newValue, ok := newFields[path]; ok {
+			changes[i].New = &newValue
+		}
+	}
+	return changes
+}
+
+func lessColumnPath(a string, b string) bool {
+	aKeys, bKeys := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(aKeys) && i < len(bKeys); i++ {
+		if aKeys[i] != bKeys[i] {
+			return lessColumnKey(aKeys[i], bKeys[i])
+		}
+	}
+	return len(aKeys) < len(bKeys)
+}
+
+// formatTableDiffs returns the changes for reviewers, e.g. "~ 1203: price 100 → 120".
+func formatTableDiffs(tableDiffs []*TableDiff) string {
+	if len(tableDiffs) == 0 {
+		return "No changes"
+	}
+
+	var lines []string
+	for _, tableDiff := range tableDiffs {
+		lines = append(lines, fmt.Sprintf("%v: %v added, %v removed, %v changed",
+			tableDiff.Table, len(tableDiff.Added), len(tableDiff.Removed), len(tableDiff.Changed)))
+		for _, key := range tableDiff.Added {
+			lines = append(lines, "  + "+key)
+		}
+		for _, key := range tableDiff.Removed {
+			lines = append(lines, "  - "+key)
+		}
+		for _, recordDiff := range tableDiff.Changed {
+			var changes []string
+			for _, change := range recordDiff.Changes {
+				changes = append(changes, fmt.Sprintf("%v %v → %v",
+					change.Path, formatDiffValue(change.Old), formatDiffValue(change.New)))
+			}
+			lines = append(lines, fmt.Sprintf("  ~ %v: %v", recordDiff.Key, strings.Join(changes, ", ")))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func formatDiffValue(value *string) string {
+	if value == nil {
+		return "(none)"
+	} else if *value == "" || strings.ContainsAny(*value, " ,") {
+		return fmt.Sprintf("%q", *value)
+	}
+	return *value
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	Convey("diff", t, func() {
+		newMasterDataList := func(fileName string, csvData string) []*MasterData {
+			csvTable, err := newCSVTable(fileName, "utf-8", []byte(csvData))
+			So(err, ShouldBeNil)
+			masterData, err := newMasterDataFromCSV(csvTable, 0)
+			So(err, ShouldBeNil)
+			return []*MasterData{masterData}
+		}
+
+		Convey(".diffMasterDataList", func() {
+			oldList := newMasterDataList("items.csv", "id,name,price,tags.0,tags.1\n1100,Potion,10,a,\n1203,Sword,100,a,b\n1204,Shield,50,,")
+			newList := newMasterDataList("items.csv", "id,name,price,tags.0,tags.1\n1203,Sword,120,a,\n1204,Shield,50,,\n1205,Bow,80,c,d")
+
+			Convey("should return the added, removed and changed records by the key", func() {
+				tableDiffs, err := diffMasterDataList(oldList, newList)
+				So(err, ShouldBeNil)
+				So(len(tableDiffs), ShouldEqual, 1)
+				So(tableDiffs[0].Table, ShouldEqual, "items")
+				So(tableDiffs[0].Added, ShouldResemble, []string{"1205"})
+				So(tableDiffs[0].Removed, ShouldResemble, []string{"1100"})
+				So(len(tableDiffs[0].Changed), ShouldEqual, 1)
+				So(tableDiffs[0].Changed[0].Key, ShouldEqual, "1203")
+				So(formatTableDiffs(tableDiffs), ShouldEqual, "items: 1 added, 1 removed, 1 changed\n"+
+					"  + 1205\n"+
+					"  - 1100\n"+
+					"  ~ 1203: price 100 → 120, tags.1 b → \"\"")
+			})
+
+			Convey("should treat the records of added tables as added records", func() {
+				tableDiffs, err := diffMasterDataList(nil, newList)
+				So(err, ShouldBeNil)
+				So(tableDiffs[0].Added, ShouldResemble, []string{"1203", "1204", "1205"})
+			})
+
+			Convey("should return no changes with the same data", func() {
+				tableDiffs, err := diffMasterDataList(oldList, oldList)
+				So(err, ShouldBeNil)
+				So(tableDiffs, ShouldBeEmpty)
+				So(formatTableDiffs(tableDiffs), ShouldEqual, "No changes")
+			})
+
+			Convey("with duplicated keys", func() {
+				Convey("should return error", func() {
+					_, err := diffMasterDataList(newMasterDataList("items.csv", "id,name\n1,a\n1,b"), newList)
+					So(err, ShouldNotBeNil)
+				})
+			})
+		})
+	})
+}
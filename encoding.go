@@ -14,6 +14,9 @@ import (
 var (
 	utf8BOM            = []byte{239, 187, 191}
 	utf8CharsetPattern = regexp.MustCompile("(?i)utf-?8$")
+
+	// encodingDetectionSize is the size of the data to detect the encoding of a large file.
+	encodingDetectionSize int64 = 64 * 1024
 )
 
 func isUTF8Charset(charsetName string) bool {
@@ -76,6 +79,22 @@ func decode(data []byte, charsetName string) ([]byte, error) {
 	return b.Bytes(), nil
 }
 
+// newDecodeReader returns the reader which decodes the data of the charset incrementally, like decode.
+func newDecodeReader(reader io.Reader, charsetName string) (io.Reader, error) {
+	encoding, _ := charset.Lookup(charsetName)
+	if encoding == nil {
+		return nil, fmt.Errorf("Unsupported charset: %v", charsetName)
+	}
+
+	decodeReader := bufio.NewReader(transform.NewReader(reader, encoding.NewDecoder()))
+	if isUTF8Charset(charsetName) {
+		if bom, err := decodeReader.Peek(len(utf8BOM)); err == nil && bytes.Equal(bom, utf8BOM) {
+			decodeReader.Discard(len(utf8BOM))
+		}
+	}
+	return decodeReader, nil
+}
+
 func detectEncoding(data []byte) (string, error) {
 	detector := chardet.NewTextDetector()
 	detected, err := detector.DetectBest(data)
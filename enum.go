@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+var enumNamePattern = regexp.MustCompile("^[A-Za-z][A-Za-z0-9_]*$")
+
+// Enum represents labels of enum columns and their integer values, which are defined in enums.yaml.
+type Enum struct {
+	name   string
+	values map[string]int
+}
+
+func newEnums(data []byte) (map[string]*Enum, error) {
+	var definitions map[string]map[string]int
+	if err := yaml.UnmarshalStrict(data, &definitions); err != nil {
+		return nil, err
+	}
+
+	enums := make(map[string]*Enum)
+	for name, values := range definitions {
+		if !enumNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("Invalid enum name: %v", name)
+		}
+		if len(values) == 0 {
+			return nil, fmt.Errorf("Enum should have at least 1 label: %v", name)
+		}
+		enums[name] = &Enum{name: name, values: values}
+	}
+	return enums, nil
+}
+
+// labels returns the labels in order of their values.
+func (e *Enum) labels() []string {
+	var labels []string
+	for label := range e.values {
+		labels = append(labels, label)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		if e.values[labels[i]] != e.values[labels[j]] {
+			return e.values[labels[i]] < e.values[labels[j]]
+		}
+		return labels[i] < labels[j]
+	})
+	return labels
+}
+
+func (e *Enum) value(label string) (int, error) {
+	value, ok := e.values[label]
+	if !ok {
+		return 0, fmt.Errorf("Unknown label of %v: %v (expected %v)", e.name, label, strings.Join(e.labels(), ", "))
+	}
+	return value, nil
+}
+
+// schema returns the JSON Schema keywords of the values. The title is the enum name for the code generators.
+func (e *Enum) schema() map[string]interface{} {
+	var values []interface{}
+	for _, label := range e.labels() {
+		// Labels can be aliases of the same value.
+		if len(values) == 0 || values[len(values)-1] != e.values[label] {
+			values = append(values, e.values[label])
+		}
+	}
+	return map[string]interface{}{"type": "integer", "enum": values, "title": e.name}
+}
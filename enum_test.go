@@ -0,0 +1,60 @@
+package main
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestEnum(t *testing.T) {
+	Convey("Enum", t, func() {
+		Convey(".newEnums", func() {
+			Convey("should return Enums", func() {
+				enums, err := newEnums([]byte("Rarity:\n  SSR: 3\n  R: 1\n  SR: 2\n"))
+				So(err, ShouldBeNil)
+				So(enums["Rarity"].labels(), ShouldResemble, []string{"R", "SR", "SSR"})
+			})
+
+			Convey("with invalid enum name", func() {
+				Convey("should return error", func() {
+					_, err := newEnums([]byte("item type:\n  A: 1\n"))
+					So(err, ShouldNotBeNil)
+				})
+			})
+
+			Convey("with non-integer value", func() {
+				Convey("should return error", func() {
+					_, err := newEnums([]byte("Rarity:\n  R: one\n"))
+					So(err, ShouldNotBeNil)
+				})
+			})
+		})
+
+		Convey("#value", func() {
+			enum := &Enum{name: "Rarity", values: map[string]int{"R": 1, "SR": 2}}
+
+			Convey("should return the value of the label", func() {
+				value, err := enum.value("SR")
+				So(err, ShouldBeNil)
+				So(value, ShouldEqual, 2)
+			})
+
+			Convey("with unknown label", func() {
+				Convey("should return error which has the labels", func() {
+					_, err := enum.value("UR")
+					So(err.Error(), ShouldEqual, "Unknown label of Rarity: UR (expected R, SR)")
+				})
+			})
+		})
+
+		Convey("#schema", func() {
+			Convey("should return the JSON Schema keywords", func() {
+				enum := &Enum{name: "Rarity", values: map[string]int{"R": 1, "Rare": 1, "SR": 2}}
+				So(enum.schema(), ShouldResemble, map[string]interface{}{
+					"type":  "integer",
+					"enum":  []interface{}{1, 2},
+					"title": "Rarity",
+				})
+			})
+		})
+	})
+}
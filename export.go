@@ -0,0 +1,389 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSVColumnTree represents the nested structure of CSV column names which are flattened from JSON.
+// The values are the values of the path by the record indexes, and a container is written in a json column
+// if isJSON is true.
+type CSVColumnTree struct {
+	isLeaf   bool
+	isJSON   bool
+	children map[string]*CSVColumnTree
+	values   map[int]interface{}
+}
+
+func newCSVColumnTree() *CSVColumnTree {
+	return &CSVColumnTree{children: make(map[string]*CSVColumnTree), values: make(map[int]interface{})}
+}
+
+func (t *CSVColumnTree) add(recordIndex int, path string, value interface{}) error {
+	t.values[recordIndex] = value
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, childValue := range v {
+			if isArrayIndex(key) {
+				return fmt.Errorf("Object key is ambiguous with array index: %v", joinColumnPath(path, key))
+			}
+			if err := t.child(key).add(recordIndex, joinColumnPath(path, key), childValue); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for i, childValue := range v {
+			key := strconv.Itoa(i)
+			if err := t.child(key).add(recordIndex, joinColumnPath(path, key), childValue); err != nil {
+				return err
+			}
+		}
+	default:
+		t.isLeaf = true
+	}
+
+	if t.isLeaf && len(t.children) > 0 {
+		return fmt.Errorf("Column is used as both a value and a container: %v", path)
+	}
+	return nil
+}
+
+func (t *CSVColumnTree) child(key string) *CSVColumnTree {
+	child, ok := t.children[key]
+	if !ok {
+		child = newCSVColumnTree()
+		t.children[key] = child
+	}
+	return child
+}
+
+func (t *CSVColumnTree) columns(path string) []*CSVExportColumn {
+	if path != "" && (len(t.children) == 0 || t.isJSON) {
+		return []*CSVExportColumn{&CSVExportColumn{name: path, kind: t.leafKind(), tree: t}}
+	}
+
+	var keys []string
+	for key := range t.children {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return lessColumnKey(keys[i], keys[j]) })
+
+	var result []*CSVExportColumn
+	for _, key := range keys {
+		result = append(result, t.children[key].columns(joinColumnPath(path, key))...)
+	}
+	return result
+}
+
+// leafKind returns the kind of the column which restores the values when the CSV data is converted again.
+// The values are typed by the same inference as conversion, and they are written in a string or json column
+// if the inference changes them, e.g. "0123" and "TRUE" strings, or mixed types.
+func (t *CSVColumnTree) leafKind() string {
+	if t.isJSON || len(t.children) > 0 {
+		return jsonColumnKind
+	}
+
+	column := &CSVColumn{}
+	isAllString := true
+	for _, value := range t.values {
+		switch value.(type) {
+		case string:
+		case bool, float64:
+			isAllString = false
+		default:
+			return jsonColumnKind
+		}
+		column.detectType(formatCSVValue(value), defaultBooleanLiterals)
+	}
+	for _, value := range t.values {
+		if converted, err := column.scalarValue(formatCSVValue(value), defaultBooleanLiterals); err != nil || converted != value {
+			if isAllString {
+				return stringColumnKind
+			}
+			return jsonColumnKind
+		}
+	}
+	return ""
+}
+
+// CSVExportColumn represents a column of the exported CSV data.
+type CSVExportColumn struct {
+	name string
+	kind string
+	tree *CSVColumnTree
+}
+
+func (c *CSVExportColumn) header() string {
+	if c.kind == "" {
+		return c.name
+	}
+	return c.name + ":" + c.kind
+}
+
+func (c *CSVExportColumn) cell(recordIndex int) (string, error) {
+	value, ok := c.tree.values[recordIndex]
+	if !ok {
+		return "", nil
+	} else if c.kind == jsonColumnKind {
+		data, err := json.Marshal(value)
+		return string(data), err
+	}
+	return formatCSVValue(value), nil
+}
+
+// flattenRecords converts the given JSON records into CSV records with dotted column names.
+// The CSV records are converted back to check the records are restored, and the containers which are not restored,
+// e.g. arrays of different lengths, are written in json columns. It returns an error if the records cannot be restored,
+// e.g. a key is missing in some records.
+func flattenRecords(records []interface{}) ([][]string, error) {
+	// The records are normalized as decoded JSON, e.g. integers are float64, to compare them with the restored records.
+	jsonData, err := json.Marshal(records)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(jsonData, &records); err != nil {
+		return nil, err
+	}
+
+	tree := newCSVColumnTree()
+	for i, record := range records {
+		if _, ok := record.(map[string]interface{}); !ok {
+			return nil, fmt.Errorf("Record should be an object: %v", record)
+		}
+		if err := tree.add(i, "", record); err != nil {
+			return nil, err
+		}
+	}
+
+	for {
+		csvRecords, err := tree.csvRecords(len(records))
+		if err != nil || len(records) == 0 || len(csvRecords[0]) == 0 {
+			return csvRecords, err
+		}
+		restoredRecords, err := restoreCSVRecords(csvRecords)
+		if err != nil {
+			return nil, fmt.Errorf("Exported CSV data cannot be converted: %v", err)
+		}
+		if len(restoredRecords) != len(records) {
+			// The rows whose first cell starts with "#" are disabled, so they are not restored.
+			for _, row := range csvRecords[1:] {
+				if isDisabledCSVRecord(row) {
+					return nil, fmt.Errorf("Value of the first column %v cannot be exported because it disables the row: %v",
+						csvRecords[0][0], row[0])
+				}
+			}
+			return nil, fmt.Errorf("Records cannot be restored from CSV data: %v of %v records are restored",
+				len(restoredRecords), len(records))
+		}
+
+		var mismatches [][]string
+		for i, record := range records {
+			mismatches = append(mismatches, findMismatchedPaths(nil, record, restoredRecords[i])...)
+		}
+		if len(mismatches) == 0 {
+			return csvRecords, nil
+		}
+		// The same path can mismatch in several records, so the nodes are marked after all of them are checked.
+		var nodes []*CSVColumnTree
+		for _, keys := range mismatches {
+			node := tree
+			for _, key := range keys {
+				if node = node.children[key]; node == nil {
+					break
+				}
+			}
+			if node == nil || node.isJSON {
+				return nil, fmt.Errorf("Value cannot be restored from CSV data: %v", strings.Join(keys, "."))
+			}
+			nodes = append(nodes, node)
+		}
+		for _, node := range nodes {
+			node.isJSON = true
+		}
+	}
+}
+
+func (t *CSVColumnTree) csvRecords(recordCount int) ([][]string, error) {
+	columns := t.columns("")
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = column.header()
+	}
+	result := [][]string{header}
+	for recordIndex := 0; recordIndex < recordCount; recordIndex++ {
+		row := make([]string, len(columns))
+		for i, column := range columns {
+			cell, err := column.cell(recordIndex)
+			if err != nil {
+				return nil, err
+			}
+			row[i] = cell
+		}
+		result = append(result, row)
+	}
+	return result, nil
+}
+
+// restoreCSVRecords converts the CSV records in the same way as CSV files.
+func restoreCSVRecords(csvRecords [][]string) ([]interface{}, error) {
+	data, err := writeCSVRecords(csvRecords)
+	if err != nil {
+		return nil, err
+	}
+	csvTable, err := newCSVTableWithOptions("export.csv", "utf-8", data, &CSVTableOptions{location: time.UTC})
+	if err != nil {
+		return nil, err
+	}
+	restoredData, err := csvTable.data()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]interface{}, len(restoredData))
+	for i, record := range restoredData {
+		result[i] = record
+	}
+	return result, nil
+}
+
+// findMismatchedPaths returns the shallowest paths whose values are different between the expected and the actual value.
+func findMismatchedPaths(keys []string, expected interface{}, actual interface{}) [][]string {
+	path := func(key string) []string {
+		return append(append([]string{}, keys...), key)
+	}
+
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		if !ok || (len(keys) > 0 && len(a) != len(e)) {
+			return [][]string{keys}
+		}
+		var result [][]string
+		for key, value := range e {
+			if actualValue, ok := a[key]; !ok {
+				result = append(result, path(key))
+			} else {
+				result = append(result, findMismatchedPaths(path(key), value, actualValue)...)
+			}
+		}
+		for key := range a {
+			if _, ok := e[key]; !ok {
+				result = append(result, path(key))
+			}
+		}
+		return result
+	case []interface{}:
+		a, ok := actual.([]interface{})
+		if !ok || len(a) != len(e) {
+			return [][]string{keys}
+		}
+		var result [][]string
+		for i, value := range e {
+			result = append(result, findMismatchedPaths(path(strconv.Itoa(i)), value, a[i])...)
+		}
+		return result
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		return [][]string{keys}
+	}
+	return nil
+}
+
+// formatCSVValue returns the cell of the scalar value.
+func formatCSVValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case bool:
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case nil:
+		return ""
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+func flattenValue(result map[string]string, path string, value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, childValue := range v {
+			flattenValue(result, joinColumnPath(path, key), childValue)
+		}
+	case []interface{}:
+		for i, childValue := range v {
+			flattenValue(result, joinColumnPath(path, strconv.Itoa(i)), childValue)
+		}
+	default:
+		result[path] = formatCSVValue(v)
+	}
+}
+
+func exportCSV(jsonData []byte) ([]byte, error) {
+	var records []interface{}
+	if err := json.Unmarshal(jsonData, &records); err != nil {
+		return nil, err
+	}
+
+	csvRecords, err := flattenRecords(records)
+	if err != nil {
+		return nil, err
+	}
+	return writeCSVRecords(csvRecords)
+}
+
+func writeCSVRecords(records [][]string) ([]byte, error) {
+	return writeCSVRecordsWithCRLF(records, false)
+}
+
+// writeCSVRecordsWithCRLF writes the records with \r\n as the line terminator if useCRLF is true,
+// which is used by the CSV files exported from spreadsheets.
+func writeCSVRecordsWithCRLF(records [][]string, useCRLF bool) ([]byte, error) {
+	var b bytes.Buffer
+	writer := csv.NewWriter(&b)
+	writer.UseCRLF = useCRLF
+	for _, record := range records {
+		// encoding/csv writes a record which has only an empty field as an empty line,
+		// and it is skipped when reading, so the field is quoted explicitly.
+		if len(record) == 1 && record[0] == "" {
+			writer.Flush()
+			if useCRLF {
+				b.WriteString("\"\"\r\n")
+			} else {
+				b.WriteString("\"\"\n")
+			}
+		} else if err := writer.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+	return b.Bytes(), writer.Error()
+}
+
+func joinColumnPath(path string, key string) string {
+	if path == "" || key == "" {
+		return path + key
+	}
+	return path + "." + key
+}
+
+func lessColumnKey(a string, b string) bool {
+	aIndex, aErr := strconv.Atoi(a)
+	bIndex, bErr := strconv.Atoi(b)
+	if aErr == nil && bErr == nil {
+		return aIndex < bIndex
+	}
+	if a == "id" || b == "id" {
+		return a == "id"
+	}
+	return a < b
+}
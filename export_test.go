@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	. "github.com/smartystreets/goconvey/convey"
+	"io/ioutil"
+	"testing"
+)
+
+func TestExport(t *testing.T) {
+	Convey("export", t, func() {
+		Convey(".flattenRecords", func() {
+			Convey("should return CSV records with dotted column names", func() {
+				records := []interface{}{
+					map[string]interface{}{
+						"name":  "foo",
+						"id":    1.0,
+						"user":  map[string]interface{}{"is_human": true},
+						"items": []interface{}{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k"},
+					},
+					map[string]interface{}{
+						"name":  "bar",
+						"id":    2.5,
+						"user":  map[string]interface{}{"is_human": false},
+						"items": []interface{}{"l", "m", "n", "o", "p", "q", "r", "s", "t", "u", "v"},
+					},
+				}
+
+				actual, err := flattenRecords(records)
+				So(err, ShouldBeNil)
+				So(actual, ShouldResemble, [][]string{
+					[]string{"id", "items.0", "items.1", "items.2", "items.3", "items.4", "items.5",
+						"items.6", "items.7", "items.8", "items.9", "items.10", "name", "user.is_human"},
+					[]string{"1", "a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "foo", "TRUE"},
+					[]string{"2.5", "l", "m", "n", "o", "p", "q", "r", "s", "t", "u", "v", "bar", "FALSE"},
+				})
+			})
+
+			Convey("should add the string kind to the strings which look like numbers or booleans", func() {
+				records := []interface{}{
+					map[string]interface{}{"id": 1.0, "code": "0123", "flag": "TRUE"},
+					map[string]interface{}{"id": 2.0, "code": "7", "flag": "FALSE"},
+				}
+				actual, err := flattenRecords(records)
+				So(err, ShouldBeNil)
+				So(actual, ShouldResemble, [][]string{
+					[]string{"id", "code:string", "flag:string"},
+					[]string{"1", "0123", "TRUE"},
+					[]string{"2", "7", "FALSE"},
+				})
+			})
+
+			Convey("should write the values which cannot be restored from dotted columns in json columns", func() {
+				records := []interface{}{
+					map[string]interface{}{"id": 1.0, "items": []interface{}{"a"}, "empty": []interface{}{}, "mixed": 1.0},
+					map[string]interface{}{"id": 2.0, "items": []interface{}{}, "empty": []interface{}{}, "mixed": "a"},
+				}
+				actual, err := flattenRecords(records)
+				So(err, ShouldBeNil)
+				So(actual, ShouldResemble, [][]string{
+					[]string{"id", "empty:json", "items:json", "mixed:json"},
+					[]string{"1", "[]", `["a"]`, "1"},
+					[]string{"2", "[]", "[]", `"a"`},
+				})
+			})
+
+			Convey("should write the values which mismatch in several records in json columns", func() {
+				records := []interface{}{
+					map[string]interface{}{"id": 1.0, "flags": []interface{}{false}},
+					map[string]interface{}{"id": 2.0, "flags": []interface{}{false, true, false}},
+					map[string]interface{}{"id": 3.0, "flags": []interface{}{true}},
+				}
+				actual, err := flattenRecords(records)
+				So(err, ShouldBeNil)
+				So(actual, ShouldResemble, [][]string{
+					[]string{"id", "flags:json"},
+					[]string{"1", "[false]"},
+					[]string{"2", "[false,true,false]"},
+					[]string{"3", "[true]"},
+				})
+			})
+
+			Convey("with a key which is missing in some records", func() {
+				Convey("should return an error", func() {
+					records := []interface{}{
+						map[string]interface{}{"id": 1.0, "name": "foo"},
+						map[string]interface{}{"id": 2.0},
+					}
+					_, err := flattenRecords(records)
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldEqual, "Value cannot be restored from CSV data: name")
+				})
+			})
+
+			Convey("with conflicting value types", func() {
+				Convey("should return an error", func() {
+					records := []interface{}{
+						map[string]interface{}{"items": []interface{}{"a"}},
+						map[string]interface{}{"items": "b"},
+					}
+					_, err := flattenRecords(records)
+					So(err, ShouldNotBeNil)
+				})
+			})
+
+			Convey("with a first column value which starts with #", func() {
+				Convey("should return an error", func() {
+					records := []interface{}{
+						map[string]interface{}{"a": "#x"},
+						map[string]interface{}{"a": "y"},
+					}
+					_, err := flattenRecords(records)
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldEqual, "Value of the first column a cannot be exported because it disables the row: #x")
+				})
+			})
+
+			Convey("with numeric object keys", func() {
+				Convey("should return an error", func() {
+					records := []interface{}{
+						map[string]interface{}{"items": map[string]interface{}{"0": "a"}},
+					}
+					_, err := flattenRecords(records)
+					So(err, ShouldNotBeNil)
+				})
+			})
+		})
+
+		Convey(".exportCSV", func() {
+			Convey("should return CSV data which is converted back to the same JSON", func() {
+				jsonData, _ := ioutil.ReadFile("./fixtures/masterdata.json")
+				csvData, err := exportCSV(jsonData)
+				So(err, ShouldBeNil)
+
+				csvTable, err := newCSVTable("masterdata.csv", "utf-8", csvData)
+				So(err, ShouldBeNil)
+				data, err := csvTable.data()
+				So(err, ShouldBeNil)
+
+				var expected, actual interface{}
+				roundTripped, _ := json.Marshal(data)
+				json.Unmarshal(jsonData, &expected)
+				json.Unmarshal(roundTripped, &actual)
+				So(actual, ShouldResemble, expected)
+			})
+
+			Convey("should return CSV data which keeps the types of ambiguous values", func() {
+				jsonData := []byte(`[
+					{ "id": 1, "code": "0123", "flag": "TRUE", "tags": [], "rates": [-1, 0.5], "memo": null },
+					{ "id": 2, "code": "0456", "flag": "x", "tags": ["a", "b"], "rates": [2, 3], "memo": "m" }
+				]`)
+				csvData, err := exportCSV(jsonData)
+				So(err, ShouldBeNil)
+
+				csvTable, err := newCSVTable("masterdata.csv", "utf-8", csvData)
+				So(err, ShouldBeNil)
+				data, err := csvTable.data()
+				So(err, ShouldBeNil)
+
+				var expected, actual interface{}
+				roundTripped, _ := json.Marshal(data)
+				json.Unmarshal(jsonData, &expected)
+				json.Unmarshal(roundTripped, &actual)
+				So(actual, ShouldResemble, expected)
+			})
+		})
+	})
+}
@@ -0,0 +1,29 @@
+module github.com/shiwano/master
+
+go 1.18
+
+require (
+	github.com/BurntSushi/toml v1.6.0
+	github.com/Knetic/govaluate v3.0.0+incompatible
+	github.com/jeffail/gabs v1.1.1
+	github.com/saintfish/chardet v0.0.0-20120816061221-3af4cd4741ca
+	github.com/santhosh-tekuri/jsonschema/v5 v5.3.1
+	github.com/smartystreets/goconvey v1.6.4
+	github.com/tj/docopt v1.0.0
+	github.com/ttacon/chalk v0.0.0-20160626202418-22c06c80ed31
+	golang.org/x/net v0.21.0
+	golang.org/x/text v0.14.0
+	gopkg.in/yaml.v2 v2.4.0
+)
+
+require (
+	github.com/gopherjs/gopherjs v0.0.0-20181017120253-0766667cb4d1 // indirect
+	github.com/jtolds/gls v4.20.0+incompatible // indirect
+	github.com/smartystreets/assertions v0.0.0-20180927180507-b2de0cb4f26d // indirect
+)
+
+// The packages are imported by the paths before the modules were renamed.
+replace (
+	github.com/jeffail/gabs => github.com/Jeffail/gabs v1.1.1
+	github.com/tj/docopt => github.com/docopt/docopt-go v0.0.0-20180111231733-ee0de3bc6815
+)
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LintRule represents a rule of spreadsheet hygiene, which is not a violation of JSON Schema.
+// Cell rules return the fixed value of a cell, and column rules return the findings of the columns.
+type LintRule struct {
+	name        string
+	message     string
+	fixCell     func(value string, column *LintColumn) string
+	checkColumn func(columns []*CSVColumn, records [][]string) []*LintFinding
+}
+
+// LintColumn represents the kind of a column for the cell rules, e.g. full-width digits are fixed only in number columns.
+// The kind is detected from the values as if they were fixed, and the booleans are nil unless it's a boolean column.
+type LintColumn struct {
+	isNumber bool
+	booleans *BooleanLiterals
+}
+
+// LintFinding represents a problem which is found by a rule. The line is 1 for the problems of the header.
+type LintFinding struct {
+	rule    string
+	line    int
+	column  string
+	message string
+	fixable bool
+}
+
+var lintRules = []*LintRule{
+	&LintRule{name: "trailing-whitespace", message: "Leading or trailing whitespace", fixCell: fixTrailingWhitespace},
+	&LintRule{name: "full-width-digits", message: "Full-width digits in a number", fixCell: fixFullWidthDigits},
+	&LintRule{name: "boolean-case", message: "Boolean should be in the case of the literal", fixCell: fixBooleanCase},
+	&LintRule{name: "unused-column", checkColumn: findUnusedColumns},
+	&LintRule{name: "array-index-gap", checkColumn: findArrayIndexGaps},
+}
+
+// enabledLintRules returns the rules except the disabled ones.
+func enabledLintRules(disabledRules []string) ([]*LintRule, error) {
+	isDisabled := make(map[string]bool)
+	for _, name := range disabledRules {
+		isDisabled[name] = true
+	}
+
+	var result []*LintRule
+	for _, rule := range lintRules {
+		if isDisabled[rule.name] {
+			delete(isDisabled, rule.name)
+		} else {
+			result = append(result, rule)
+		}
+	}
+	for name := range isDisabled {
+		return nil, fmt.Errorf("Unknown lint rule: %v", name)
+	}
+	return result, nil
+}
+
+func (f *LintFinding) String() string {
+	text := fmt.Sprintf("%v: %v: %v [%v]", f.line, f.column, f.message, f.rule)
+	if f.fixable {
+		text += " (fixable)"
+	}
+	return text
+}
+
+// lintCSV returns the findings of the rules in order of the lines, and the fixed CSV data if some cells are fixed.
+// Comment columns, ignored columns and disabled rows are not checked.
+func lintCSV(data []byte, rules []*LintRule, options *CSVTableOptions) ([]*LintFinding, []byte, error) {
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(records) < 2 {
+		return nil, nil, fmt.Errorf("CSV data should have 2 rows at a minimum")
+	}
+
+	checkedIndexes, err := csvColumnIndexesWithoutIgnored(records[0], options.ignoreColumns)
+	if err != nil {
+		return nil, nil, err
+	}
+	isChecked := make(map[int]bool)
+	for _, index := range checkedIndexes {
+		isChecked[index] = true
+	}
+
+	var findings []*LintFinding
+	isFixed := false
+	lintColumns := detectLintColumns(records, options)
+	for recordIndex, record := range records[1:] {
+		if isDisabledCSVRecord(record) {
+			continue
+		}
+		for i, value := range record {
+			if !isChecked[i] {
+				continue
+			}
+			for _, rule := range rules {
+				if rule.fixCell == nil {
+					continue
+				}
+				if fixed := rule.fixCell(value, lintColumns[i]); fixed != value {
+					findings = append(findings, &LintFinding{
+						rule:    rule.name,
+						line:    recordIndex + 2,
+						column:  records[0][i],
+						message: fmt.Sprintf("%v: %q", rule.message, value),
+						fixable: true,
+					})
+					record[i], value, isFixed = fixed, fixed, true
+				}
+			}
+		}
+	}
+
+	enabledRecords, _ := removeDisabledCSVRecords(records)
+	enabledRecords, err = removeIgnoredCSVColumns(enabledRecords, options.ignoreColumns)
+	if err != nil {
+		return nil, nil, err
+	}
+	columns, err := newCSVColumns(enabledRecords, options)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, rule := range rules {
+		if rule.checkColumn != nil {
+			for _, finding := range rule.checkColumn(columns, enabledRecords[1:]) {
+				finding.rule = rule.name
+				findings = append(findings, finding)
+			}
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].line < findings[j].line
+	})
+
+	if !isFixed {
+		return findings, nil, nil
+	}
+	fixedData, err := writeCSVRecordsWithCRLF(records, bytes.Contains(data, []byte("\r\n")))
+	return findings, fixedData, err
+}
+
+// detectLintColumns detects the kinds of the columns whose kinds are not declared in the header.
+// A column is a number or boolean column if all of its non-empty values are numbers or boolean literals.
+func detectLintColumns(records [][]string, options *CSVTableOptions) []*LintColumn {
+	columns := make([]*LintColumn, len(records[0]))
+	for i, header := range records[0] {
+		columns[i] = &LintColumn{}
+		if strings.Contains(header, ":") {
+			continue
+		}
+
+		booleans := options.booleanLiterals(header)
+		isNumber, isBool, isEmpty := true, true, true
+		for _, record := range records[1:] {
+			value := strings.TrimSpace(record[i])
+			if isDisabledCSVRecord(record) || value == "" {
+				continue
+			}
+			_, isLiteral := booleans.parse(value)
+			isNumber = isNumber && numberValuePattern.MatchString(toHalfWidthDigits(value))
+			isBool = isBool && isLiteral
+			isEmpty = false
+		}
+		columns[i].isNumber = isNumber && !isEmpty
+		if isBool && !isEmpty {
+			columns[i].booleans = booleans
+		}
+	}
+	return columns
+}
+
+func fixTrailingWhitespace(value string, column *LintColumn) string {
+	return strings.TrimSpace(value)
+}
+
+// fixFullWidthDigits converts full-width digits to ASCII digits in number columns.
+func fixFullWidthDigits(value string, column *LintColumn) string {
+	if !column.isNumber {
+		return value
+	}
+	return toHalfWidthDigits(value)
+}
+
+func toHalfWidthDigits(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '０' && r <= '９' {
+			return '0' + (r - '０')
+		}
+		return r
+	}, strings.Replace(value, "．", ".", -1))
+}
+
+// fixBooleanCase converts the values to the boolean literals of boolean columns, e.g. "true" to "TRUE".
+func fixBooleanCase(value string, column *LintColumn) string {
+	if column.booleans == nil {
+		return value
+	}
+	for _, literal := range append(append([]string{}, column.booleans.TrueValues...), column.booleans.FalseValues...) {
+		if strings.EqualFold(literal, value) {
+			return literal
+		}
+	}
+	return value
+}
+
+func findUnusedColumns(columns []*CSVColumn, records [][]string) []*LintFinding {
+	var findings []*LintFinding
+	for i, column := range columns {
+		isUsed := false
+		for _, record := range records {
+			isUsed = isUsed || strings.TrimSpace(record[i]) != ""
+		}
+		if !isUsed && len(records) > 0 {
+			findings = append(findings, &LintFinding{line: 1, column: column.name, message: "Column is always empty"})
+		}
+	}
+	return findings
+}
+
+// findArrayIndexGaps finds the numbered arrays whose indexes are not sequential, e.g. items.0 and items.2.
+func findArrayIndexGaps(columns []*CSVColumn, records [][]string) []*LintFinding {
+	var paths []string
+	indexes := make(map[string]map[int]bool)
+	for _, column := range columns {
+		keys := strings.Split(column.name, ".")
+		for i, key := range keys {
+			if !isArrayIndex(key) {
+				continue
+			}
+			index, _ := strconv.Atoi(key)
+			path := strings.Join(keys[:i], ".")
+			if indexes[path] == nil {
+				indexes[path] = make(map[int]bool)
+				paths = append(paths, path)
+			}
+			indexes[path][index] = true
+		}
+	}
+
+	var findings []*LintFinding
+	for _, path := range paths {
+		var missings []string
+		maxIndex := 0
+		for index := range indexes[path] {
+			if index > maxIndex {
+				maxIndex = index
+			}
+		}
+		for index := 0; index < maxIndex; index++ {
+			if !indexes[path][index] {
+				missings = append(missings, strconv.Itoa(index))
+			}
+		}
+		if len(missings) > 0 {
+			findings = append(findings, &LintFinding{
+				line:    1,
+				column:  path,
+				message: fmt.Sprintf("Array indexes are missing: %v", strings.Join(missings, ", ")),
+			})
+		}
+	}
+	return findings
+}
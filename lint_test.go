@@ -0,0 +1,87 @@
+package main
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	Convey("lint", t, func() {
+		Convey(".lintCSV", func() {
+			Convey("should return the findings with the locations and the fixed data", func() {
+				csvData := []byte("id,name,price,flag,items.0,items.2,memo,#note\r\n" +
+					"1, Sword ,１００,true,a,b,, x \r\n" +
+					"#2,Shield ,１,true,,,,\r\n" +
+					"3,Bow,１２．５,FALSE,,,,\r\n")
+				findings, fixed, err := lintCSV(csvData, lintRules, &CSVTableOptions{})
+				So(err, ShouldBeNil)
+
+				var actual []string
+				for _, finding := range findings {
+					actual = append(actual, finding.String())
+				}
+				So(actual, ShouldResemble, []string{
+					"1: memo: Column is always empty [unused-column]",
+					"1: items: Array indexes are missing: 1 [array-index-gap]",
+					`2: name: Leading or trailing whitespace: " Sword " [trailing-whitespace] (fixable)`,
+					`2: price: Full-width digits in a number: "１００" [full-width-digits] (fixable)`,
+					`2: flag: Boolean should be in the case of the literal: "true" [boolean-case] (fixable)`,
+					`4: price: Full-width digits in a number: "１２．５" [full-width-digits] (fixable)`,
+				})
+				So(string(fixed), ShouldEqual, "id,name,price,flag,items.0,items.2,memo,#note\r\n"+
+					"1,Sword,100,TRUE,a,b,,\" x \"\r\n"+
+					"#2,Shield ,１,true,,,,\r\n"+
+					"3,Bow,12.5,FALSE,,,,\r\n")
+			})
+
+			Convey("should fix the cells only in the columns of the kinds", func() {
+				csvData := []byte("id,name,code:string,flag\n1,１号,１２,true\n2,true,３,no\n")
+				findings, fixed, err := lintCSV(csvData, lintRules, &CSVTableOptions{})
+				So(err, ShouldBeNil)
+				So(findings, ShouldBeEmpty)
+				So(fixed, ShouldBeNil)
+			})
+
+			Convey("should fix the case of the boolean literals of the column", func() {
+				options := &CSVTableOptions{
+					booleans:       &BooleanLiterals{TrueValues: []string{"yes"}, FalseValues: []string{"no"}},
+					columnBooleans: map[string]*BooleanLiterals{"flag": {TrueValues: []string{"○"}, FalseValues: []string{"×"}}},
+				}
+				csvData := []byte("id,enabled,flag\n1,Yes,○\n2,no,×\n")
+				findings, fixed, err := lintCSV(csvData, lintRules, options)
+				So(err, ShouldBeNil)
+				So(len(findings), ShouldEqual, 1)
+				So(findings[0].String(), ShouldEqual,
+					`2: enabled: Boolean should be in the case of the literal: "Yes" [boolean-case] (fixable)`)
+				So(string(fixed), ShouldEqual, "id,enabled,flag\n1,yes,○\n2,no,×\n")
+			})
+
+			Convey("should not check the ignored columns", func() {
+				options := &CSVTableOptions{ignoreColumns: []string{"memo*"}}
+				csvData := []byte("id,name,memo:json,memo_price\n1,Sword, x ,１\n")
+				findings, fixed, err := lintCSV(csvData, lintRules, options)
+				So(err, ShouldBeNil)
+				So(findings, ShouldBeEmpty)
+				So(fixed, ShouldBeNil)
+			})
+
+			Convey("should return no fixed data without fixable findings", func() {
+				findings, fixed, err := lintCSV([]byte("id,name\n1,Sword\n"), lintRules, &CSVTableOptions{})
+				So(err, ShouldBeNil)
+				So(findings, ShouldBeEmpty)
+				So(fixed, ShouldBeNil)
+			})
+		})
+
+		Convey(".enabledLintRules", func() {
+			Convey("should return the rules except the disabled ones", func() {
+				rules, err := enabledLintRules([]string{"unused-column"})
+				So(err, ShouldBeNil)
+				So(len(rules), ShouldEqual, len(lintRules)-1)
+
+				_, err = enabledLintRules([]string{"unknown"})
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
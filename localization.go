@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jeffail/gabs"
+)
+
+// parseLocales returns the locales without the spaces around them, e.g. "ja, en".
+func parseLocales(values []string) ([]string, error) {
+	var locales []string
+	for _, value := range values {
+		locale := strings.TrimSpace(value)
+		if locale == "" {
+			return nil, fmt.Errorf("Locale should not be empty: %v", strings.Join(values, ","))
+		}
+		locales = append(locales, locale)
+	}
+	return locales, nil
+}
+
+// isStringTableJSONPath reports whether the file is the string table of a locale, e.g. "items.ja.json",
+// which is output next to the JSON file of the table.
+func isStringTableJSONPath(filePath string) bool {
+	name := strings.TrimSuffix(filepath.Base(filePath), ".json")
+	separatorIndex := strings.LastIndex(name, ".")
+	if separatorIndex <= 0 {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(filepath.Dir(filePath), name[:separatorIndex]+".json"))
+	return err == nil
+}
+
+// localize removes the localized values, e.g. {"name": {"ja": "剣", "en": "Sword"}} of "name.ja" and "name.en" columns,
+// from the records, and returns the string tables of the locales and the missing translations.
+// The keys of the string tables are the paths of the values prefixed with the key of the record, e.g. "1.name".
+func (m *MasterData) localize(locales []string) ([]*MasterData, []string, error) {
+	isLocale := make(map[string]bool)
+	stringTables := make(map[string]map[string]interface{})
+	for _, locale := range locales {
+		isLocale[locale] = true
+		stringTables[locale] = make(map[string]interface{})
+	}
+
+	var missings []string
+	for i, record := range m.records() {
+		key, ok := lookupPath(record, m.keyColumn)
+		if !ok {
+			return nil, nil, fmt.Errorf("Record %v has no key: %v", i, m.keyColumn)
+		}
+		localizeValue(record, fmt.Sprint(key), isLocale, func(path string, values map[string]interface{}) {
+			for _, locale := range locales {
+				if value, ok := values[locale]; ok && !isEmptyLocalizedValue(value) {
+					stringTables[locale][path] = value
+				} else {
+					missings = append(missings, fmt.Sprintf("%v (%v)", path, locale))
+				}
+			}
+		})
+	}
+
+	result := make([]*MasterData, len(locales))
+	for i, locale := range locales {
+		container, err := gabs.Consume(stringTables[locale])
+		if err != nil {
+			return nil, nil, err
+		}
+		result[i] = &MasterData{
+			fileName:  m.tableName() + "." + locale + ".json",
+			indent:    m.indent,
+			container: container,
+		}
+	}
+	sort.Strings(missings)
+	return result, missings, nil
+}
+
+// localizeValue walks the value, and removes the objects whose keys are all locales after passing them to the callback.
+func localizeValue(value interface{}, path string, isLocale map[string]bool, callback func(string, map[string]interface{})) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			childPath := path + "." + key
+			if values, ok := child.(map[string]interface{}); ok && isLocalizedValue(values, isLocale) {
+				callback(childPath, values)
+				delete(v, key)
+			} else {
+				localizeValue(child, childPath, isLocale, callback)
+			}
+		}
+	case []interface{}:
+		for i, child := range v {
+			localizeValue(child, path+"."+strconv.Itoa(i), isLocale, callback)
+		}
+	}
+}
+
+func isLocalizedValue(values map[string]interface{}, isLocale map[string]bool) bool {
+	for key := range values {
+		if !isLocale[key] {
+			return false
+		}
+	}
+	return len(values) > 0
+}
+
+func isEmptyLocalizedValue(value interface{}) bool {
+	return value == nil || strings.TrimSpace(fmt.Sprint(value)) == ""
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestLocalization(t *testing.T) {
+	Convey("localization", t, func() {
+		Convey(".parseLocales", func() {
+			Convey("should return the locales without the spaces", func() {
+				actual, err := parseLocales([]string{"ja", " en "})
+				So(err, ShouldBeNil)
+				So(actual, ShouldResemble, []string{"ja", "en"})
+			})
+
+			Convey("with empty locale", func() {
+				Convey("should return error", func() {
+					_, err := parseLocales([]string{"ja", "", "en"})
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldEqual, "Locale should not be empty: ja,,en")
+				})
+			})
+		})
+	})
+
+	Convey("MasterData", t, func() {
+		Convey("#localize", func() {
+			csvData := []byte("id,name.ja,name.en,skills.0.desc.ja,skills.0.desc.en,meta.ja,meta.id\n" +
+				"1,剣,Sword,斬る,Slash,a,1\n" +
+				"2,盾,,守る,Guard,b,2\n")
+			csvTable, _ := newCSVTable("items.csv", "utf-8", csvData)
+			masterData, _ := newMasterDataFromCSV(csvTable, 0)
+
+			Convey("should split the localized values into the string tables", func() {
+				stringTables, missings, err := masterData.localize([]string{"ja", "en"})
+				So(err, ShouldBeNil)
+				So(masterData.json(), ShouldEqual,
+					`[{"id":1,"meta":{"id":1,"ja":"a"},"skills":[{}]},{"id":2,"meta":{"id":2,"ja":"b"},"skills":[{}]}]`)
+				So(len(stringTables), ShouldEqual, 2)
+				So(stringTables[0].fileName, ShouldEqual, "items.ja.json")
+				So(stringTables[0].json(), ShouldEqual,
+					`{"1.name":"剣","1.skills.0.desc":"斬る","2.name":"盾","2.skills.0.desc":"守る"}`)
+				So(stringTables[1].fileName, ShouldEqual, "items.en.json")
+				So(stringTables[1].json(), ShouldEqual, `{"1.name":"Sword","1.skills.0.desc":"Slash","2.skills.0.desc":"Guard"}`)
+				So(missings, ShouldResemble, []string{"2.name (en)"})
+			})
+
+			Convey("should report the locales which have no columns as missing translations", func() {
+				_, missings, err := masterData.localize([]string{"ja", "en", "zh"})
+				So(err, ShouldBeNil)
+				So(missings, ShouldResemble, []string{
+					"1.name (zh)", "1.skills.0.desc (zh)", "2.name (en)", "2.name (zh)", "2.skills.0.desc (zh)",
+				})
+			})
+		})
+	})
+}
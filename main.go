@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
+	_ "time/tzdata"
 
 	"github.com/tj/docopt"
 	"github.com/ttacon/chalk"
@@ -14,20 +17,42 @@ const version = "0.5.0"
 const usage = `
 Usage:
   master [options] <file-or-directory>
+  master export-csv [options] <json-file-or-directory>
+  master diff [options] <old-file-or-directory> <new-file-or-directory>
+  master lint [options] <file-or-directory>
   master -h | --help
   master --version
 
 Options:
-  -d, --output-directory string  Specify the output directory (default: <file-or-directory>).
-  -s, --schema-directory string  Specify the JSON Schema directory (default: <file-or-directory>).
-  -e, --encoding string          CSV file encoding [default: auto]. Supported encodings are https://goo.gl/T3zICN
-  -E, --fix-encoding             Fix the CSV file encoding if it is different from --encoding.
-  -n, --no-output-file           No file output. If file is given, print JSON string to stdout.
-  -S, --output-schema            Output JSON Schema from CSV files.
-  -V, --skip-validation          Skip validation by JSON Schema.
-  -j, --no-schema-suffix         Disable to use *.schema.json suffix pattern.
-  -h, --help                     Output help information.
-  -v, --version                  Output version.
+  -d, --output-directory string   Specify the output directory (default: <file-or-directory>).
+  -s, --schema-directory string   Specify the JSON Schema directory (default: <file-or-directory>).
+  -a, --asset-directory string    Specify the directory which asset-path format values are relative to.
+  -e, --encoding string           CSV file encoding (default: auto). Supported encodings are https://goo.gl/T3zICN
+  -E, --fix-encoding              Fix the CSV file encoding if it is different from --encoding.
+  -z, --timezone string           Time zone of datetime columns, e.g. Asia/Tokyo (default: UTC).
+  -T, --datetime-format string    Output of datetime columns: rfc3339 or unix (default: rfc3339).
+  -D, --variant string            Overlay <table>.<variant>.csv files, and output into <output-directory>/<variant>.
+  -L, --locales string            Split localized columns, e.g. name.ja, into <table>.<locale>.json by locales, e.g. ja,en.
+  -m, --stream                    Convert CSV files record by record without validation to keep memory usage low.
+  -o, --format string             Output format of master data: json or jsonl (default: json).
+  -n, --no-output-file            No file output. If file is given, print JSON string to stdout.
+  -S, --output-schema             Output JSON Schema from CSV files.
+  -l, --schema-strictness string  Constraints inferred in JSON Schema: basic, standard or strict (default: basic).
+  -U, --update-schema             Merge inferred JSON Schema into existing schema files.
+  -t, --output-typescript         Output TypeScript type definitions next to JSON files.
+  -r, --readonly-typescript       Add readonly modifiers to TypeScript type definitions.
+  -p, --output-proto              Output Protocol Buffers schema (*.proto) next to JSON files.
+  -b, --output-protobuf           Output Protocol Buffers binary (*.pb) next to JSON files.
+  -F, --diff-format string        Output format of diff: text or json (default: text).
+  -f, --fix                       Fix the problems found by lint in CSV files if possible.
+  -V, --skip-validation           Skip validation by JSON Schema and rules.
+  -j, --no-schema-suffix          Disable to use *.schema.json suffix pattern.
+  -N, --disable string            Disable the flags enabled in master.yaml, e.g. output-schema,stream.
+  -h, --help                      Output help information.
+  -v, --version                   Output version.
+
+The options are also read from master.yaml or master.toml in <file-or-directory>,
+and the command line options take priority over it.
 `
 
 func main() {
@@ -36,39 +61,151 @@ func main() {
 		fatalf("Failed to parse arguments: %v\n%v", args, err)
 	}
 
-	var file, dir string
-	fileOrDir := resolvePath(args["<file-or-directory>"].(string))
-	stat, err := os.Stat(fileOrDir)
-	if os.IsNotExist(err) {
-		fatalf("No such file or directory: %v\n%v", fileOrDir, err)
-	}
-	if stat.IsDir() {
-		dir = fileOrDir
+	isExportCSV := args["export-csv"].(bool)
+	isDiff := args["diff"].(bool)
+	isLint := args["lint"].(bool)
+
+	if isExportCSV {
+		newCliFromArgs(args, args["<json-file-or-directory>"].(string)).exportCSV()
+	} else if isDiff {
+		// Each version is read with its own configuration, because master.yaml, enums.yaml and relations.yaml can be changed.
+		oldCli := newCliFromArgs(args, args["<old-file-or-directory>"].(string))
+		oldCli.diff(newCliFromArgs(args, args["<new-file-or-directory>"].(string)))
+	} else if isLint {
+		newCliFromArgs(args, args["<file-or-directory>"].(string)).lint()
 	} else {
-		file = fileOrDir
-		dir = filepath.Dir(file)
+		newCliFromArgs(args, args["<file-or-directory>"].(string)).run()
+	}
+}
+
+// newCliFromArgs returns the Cli of the file or directory with the command line options
+// and the configuration file in the directory.
+func newCliFromArgs(args map[string]interface{}, fileOrDir string) *Cli {
+	file, dir := splitFileOrDir(fileOrDir)
+	config, configPath, err := loadConfig(dir)
+	if err != nil {
+		fatalf("Failed to load configuration: %v\n%v", configPath, err)
+	}
+	if config == nil {
+		config = &Config{}
+	}
+
+	var assetDir string
+	if assetDirOption := stringOption(args, "--asset-directory", config.AssetDirectory, ""); assetDirOption != "" {
+		assetDir = resolvePath(assetDirOption)
 	}
 
-	if args["--output-directory"] == nil {
-		args["--output-directory"] = dir
+	location, err := time.LoadLocation(stringOption(args, "--timezone", config.Timezone, "UTC"))
+	if err != nil {
+		fatalf("Failed to parse arguments\n%v", err)
 	}
-	if args["--schema-directory"] == nil {
-		args["--schema-directory"] = dir
+	datetimeFormat, err := parseDatetimeFormat(stringOption(args, "--datetime-format", config.DatetimeFormat, "rfc3339"))
+	if err != nil {
+		fatalf("Failed to parse arguments\n%v", err)
 	}
 
-	cli := &Cli{
-		dir:            dir,
-		file:           file,
-		outputDir:      resolvePath(args["--output-directory"].(string)),
-		schemaDir:      resolvePath(args["--schema-directory"].(string)),
-		encoding:       args["--encoding"].(string),
-		fixEncoding:    args["--fix-encoding"].(bool),
-		noOutputFile:   args["--no-output-file"].(bool),
-		outputSchema:   args["--output-schema"].(bool),
-		skipValidation: args["--skip-validation"].(bool),
-		noSchemaSuffix: args["--no-schema-suffix"].(bool),
+	format := stringOption(args, "--format", config.Format, "json")
+	if format != "json" && format != "jsonl" {
+		fatalf("Failed to parse arguments\nUnknown format: %v", format)
+	}
+
+	diffFormat := stringOption(args, "--diff-format", "", "text")
+	if diffFormat != "text" && diffFormat != "json" {
+		fatalf("Failed to parse arguments\nUnknown diff format: %v", diffFormat)
+	}
+
+	schemaStrictness, err := parseSchemaStrictness(stringOption(args, "--schema-strictness", config.SchemaStrictness, "basic"))
+	if err != nil {
+		fatalf("Failed to parse arguments\n%v", err)
+	}
+
+	outputDir := resolvePath(stringOption(args, "--output-directory", config.OutputDirectory, dir))
+	variant := stringOption(args, "--variant", config.Variant, "")
+	if strings.ContainsAny(variant, "./\\") {
+		fatalf("Failed to parse arguments\nInvalid variant: %v", variant)
+	} else if variant != "" {
+		outputDir = filepath.Join(outputDir, variant)
+	}
+
+	disabledOptions := make(map[string]bool)
+	if args["--disable"] != nil {
+		for _, name := range strings.Split(args["--disable"].(string), ",") {
+			name = "--" + strings.TrimSpace(name)
+			if _, ok := args[name].(bool); !ok {
+				fatalf("Failed to parse arguments\nUnknown flag to disable: %v", name)
+			}
+			disabledOptions[name] = true
+		}
+	}
+
+	locales := config.Locales
+	if args["--locales"] != nil {
+		locales = strings.Split(args["--locales"].(string), ",")
+	}
+	if locales, err = parseLocales(locales); err != nil {
+		fatalf("Failed to parse arguments\n%v", err)
+	}
+
+	return &Cli{
+		dir:                dir,
+		file:               file,
+		outputDir:          outputDir,
+		schemaDir:          resolvePath(stringOption(args, "--schema-directory", config.SchemaDirectory, dir)),
+		assetDir:           assetDir,
+		encoding:           stringOption(args, "--encoding", config.Encoding, "auto"),
+		fixEncoding:        boolOption(args, "--fix-encoding", config.FixEncoding, disabledOptions),
+		location:           location,
+		datetimeFormat:     datetimeFormat,
+		noOutputFile:       boolOption(args, "--no-output-file", config.NoOutputFile, disabledOptions),
+		outputSchema:       boolOption(args, "--output-schema", config.OutputSchema, disabledOptions),
+		schemaStrictness:   schemaStrictness,
+		updateSchema:       boolOption(args, "--update-schema", config.UpdateSchema, disabledOptions),
+		skipValidation:     boolOption(args, "--skip-validation", config.SkipValidation, disabledOptions),
+		noSchemaSuffix:     boolOption(args, "--no-schema-suffix", config.NoSchemaSuffix, disabledOptions),
+		outputTypeScript:   boolOption(args, "--output-typescript", config.OutputTypeScript, disabledOptions),
+		readonlyTypeScript: boolOption(args, "--readonly-typescript", config.ReadonlyTypeScript, disabledOptions),
+		outputProto:        boolOption(args, "--output-proto", config.OutputProto, disabledOptions),
+		outputProtobuf:     boolOption(args, "--output-protobuf", config.OutputProtobuf, disabledOptions),
+		variant:            variant,
+		variants:           config.Variants,
+		locales:            locales,
+		format:             format,
+		diffFormat:         diffFormat,
+		fix:                args["--fix"].(bool),
+		disabledLintRules:  config.DisabledLintRules,
+		stream:             boolOption(args, "--stream", config.Stream, disabledOptions),
+		booleanLiterals:    config.BooleanLiterals,
+		fileConfigs:        config.Files,
+	}
+}
+
+// stringOption returns the value of the command line option, the value in the configuration file, or the default value.
+func stringOption(args map[string]interface{}, name string, configValue string, defaultValue string) string {
+	if value, ok := args[name].(string); ok {
+		return value
+	} else if configValue != "" {
+		return configValue
+	}
+	return defaultValue
+}
+
+// boolOption returns whether the command line flag is given, or the flag is enabled in the configuration file
+// and not disabled by --disable.
+func boolOption(args map[string]interface{}, name string, configValue bool, disabledOptions map[string]bool) bool {
+	return args[name].(bool) || (configValue && !disabledOptions[name])
+}
+
+// splitFileOrDir returns the resolved file path and its directory, or only the directory if the path is a directory.
+func splitFileOrDir(path string) (string, string) {
+	fileOrDir := resolvePath(path)
+	stat, err := os.Stat(fileOrDir)
+	if os.IsNotExist(err) {
+		fatalf("No such file or directory: %v\n%v", fileOrDir, err)
+	}
+	if stat.IsDir() {
+		return "", fileOrDir
 	}
-	cli.run()
+	return fileOrDir, filepath.Dir(fileOrDir)
 }
 
 func fatalf(msg string, args ...interface{}) {
@@ -1,17 +1,21 @@
 package main
 
 import (
+	"bytes"
+	"fmt"
 	"github.com/jeffail/gabs"
 	"path/filepath"
-	"sort"
 	"strings"
 )
 
 // MasterData represents structured master data which is converted from CSV file.
 type MasterData struct {
-	fileName  string
-	indent    string
-	container *gabs.Container
+	fileName      string
+	indent        string
+	keyColumn     string
+	container     *gabs.Container
+	columnSchemas map[string]map[string]interface{}
+	enums         map[string]*Enum
 }
 
 func newMasterData(path string, jsonText string, indent int) (*MasterData, error) {
@@ -39,9 +43,12 @@ func newMasterDataFromCSV(csvTable *CSVTable, indent int) (*MasterData, error) {
 	}
 
 	masterData := &MasterData{
-		fileName:  strings.Replace(csvTable.fileName, ".csv", ".json", 1),
-		indent:    strings.Repeat(" ", indent),
-		container: container,
+		fileName:      strings.Replace(csvTable.fileName, ".csv", ".json", 1),
+		indent:        strings.Repeat(" ", indent),
+		keyColumn:     csvTable.keyColumn(),
+		container:     container,
+		columnSchemas: csvTable.columnSchemas(),
+		enums:         csvTable.enums(),
 	}
 	return masterData, nil
 }
@@ -53,49 +60,70 @@ func (m *MasterData) json() string {
 	return m.container.StringIndent("", m.indent)
 }
 
-func (m *MasterData) jsonSchema() string {
-	schema := getJSONSchemaRecursively(m.container.Data())
-	schema.Set(m.fileName, "title")
-	schema.Set("http://json-schema.org/draft-04/schema#", "$schema")
-	if m.indent == "" {
-		return schema.String()
+// jsonLines returns the records as JSON Lines, which has a compact JSON record per line.
+func (m *MasterData) jsonLines() (string, error) {
+	var b bytes.Buffer
+	writer := newJSONLinesWriter(&b)
+	for _, record := range m.records() {
+		if err := writer.write(record); err != nil {
+			return "", err
+		}
 	}
-	return schema.StringIndent("", m.indent)
+	err := writer.close()
+	return b.String(), err
 }
 
-func getJSONSchemaRecursively(obj interface{}) *gabs.Container {
-	schema := gabs.New()
+func (m *MasterData) tableName() string {
+	return strings.TrimSuffix(m.fileName, ".json")
+}
 
-	switch obj.(type) {
+// records returns the top-level array items, or nil if the data is not an array.
+func (m *MasterData) records() []interface{} {
+	switch data := m.container.Data().(type) {
 	case []map[string]interface{}:
-		objAsArray := obj.([]map[string]interface{})
-		if len(objAsArray) > 0 {
-			schema.Set("array", "type")
-			schema.Set(getJSONSchemaRecursively(objAsArray[0]).Data(), "items")
+		records := make([]interface{}, len(data))
+		for i, record := range data {
+			records[i] = record
 		}
+		return records
 	case []interface{}:
-		objAsArray := obj.([]interface{})
-		if len(objAsArray) > 0 {
-			schema.Set("array", "type")
-			schema.Set(getJSONSchemaRecursively(objAsArray[0]).Data(), "items")
-		}
-	case map[string]interface{}:
-		objAsMap := obj.(map[string]interface{})
-		schema.Set("object", "type")
-		var keys []string
-		for key, v := range objAsMap {
-			schema.SetP(getJSONSchemaRecursively(v).Data(), "properties."+key)
-			keys = append(keys, key)
-		}
-		sort.Strings(keys)
-		schema.Set(false, "additionalProperties")
-		schema.Set(keys, "required")
-	case string:
-		schema.Set("string", "type")
-	case bool:
-		schema.Set("boolean", "type")
-	default:
-		schema.Set("number", "type")
+		return data
+	}
+	return nil
+}
+
+func (m *MasterData) jsonSchema(strictness SchemaStrictness) string {
+	schema := m.schema(strictness)
+	if m.indent == "" {
+		return schema.String()
+	}
+	return schema.StringIndent("", m.indent)
+}
+
+func (m *MasterData) mergedJSONSchema(existingText string, strictness SchemaStrictness) (string, []string, error) {
+	existing, err := gabs.ParseJSON([]byte(existingText))
+	if err != nil {
+		return "", nil, err
 	}
+	existingSchema, ok := existing.Data().(map[string]interface{})
+	if !ok {
+		return "", nil, fmt.Errorf("JSON Schema should be an object: %v", m.fileName)
+	}
+	inferredSchema, _ := m.schema(strictness).Data().(map[string]interface{})
+
+	changes := mergeJSONSchema(existingSchema, inferredSchema, "")
+	if m.indent == "" {
+		return existing.String(), changes, nil
+	}
+	return existing.StringIndent("", m.indent), changes, nil
+}
+
+func (m *MasterData) schema(strictness SchemaStrictness) *gabs.Container {
+	schema := getJSONSchema(m.container.Data(), strictness)
+	if schemaData, ok := schema.Data().(map[string]interface{}); ok {
+		applyColumnSchemas(schemaData, m.columnSchemas)
+	}
+	schema.Set(m.fileName, "title")
+	schema.Set("http://json-schema.org/draft-04/schema#", "$schema")
 	return schema
 }
@@ -14,6 +14,22 @@ func TestMasterData(t *testing.T) {
 			})
 		})
 
+		Convey("#jsonLines", func() {
+			Convey("should return a compact JSON record per line", func() {
+				masterData, _ := newMasterData("foo.json", `[{"str":"foo"},{"str":"<bar>"}]`, 2)
+				actual, err := masterData.jsonLines()
+				So(err, ShouldBeNil)
+				So(actual, ShouldEqual, "{\"str\":\"foo\"}\n{\"str\":\"\\u003cbar\\u003e\"}\n")
+			})
+
+			Convey("should return empty string without records", func() {
+				masterData, _ := newMasterData("foo.json", `[]`, 2)
+				actual, err := masterData.jsonLines()
+				So(err, ShouldBeNil)
+				So(actual, ShouldEqual, "")
+			})
+		})
+
 		Convey("#jsonSchema", func() {
 			Convey("should return the valid JSON Schema string", func() {
 				jsonText := `[
@@ -21,7 +37,15 @@ func TestMasterData(t *testing.T) {
 				{ "str": "bar", "number": 2, "bool": false }
 				]`
 				masterData, _ := newMasterData("foo.json", jsonText, 0)
-				So(validateJSON(jsonText, masterData.jsonSchema()), ShouldBeNil)
+				So(validateJSONWithSchemaText(jsonText, masterData.jsonSchema(basicSchemaStrictness)), ShouldBeNil)
+			})
+
+			Convey("should have the schemas of typed columns", func() {
+				csvTable, _ := newCSVTable("foo.csv", "utf-8", []byte("id,events.0.start_at:datetime\n1,2026/10/01\n2,"))
+				masterData, _ := newMasterDataFromCSV(csvTable, 0)
+				schema := masterData.schema(basicSchemaStrictness)
+				So(schema.Path("items.properties.events.items.properties.start_at").String(), ShouldEqual,
+					`{"format":"date-time","type":"string"}`)
 			})
 		})
 	})
@@ -0,0 +1,472 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	protoWireVarint  = 0
+	protoWireFixed64 = 1
+	protoWireBytes   = 2
+)
+
+var (
+	protoInvalidCharPattern = regexp.MustCompile("[^A-Za-z0-9_]+")
+	protoBlockPattern       = regexp.MustCompile(`^\s*(message|enum) (\w+) \{$`)
+	protoFieldPattern       = regexp.MustCompile(`^\s*(?:repeated )?[\w.]+ (\w+) = (\d+);$`)
+	protoReservedPattern    = regexp.MustCompile(`^\s*reserved (.+);$`)
+)
+
+// ProtoMessage represents a Protocol Buffers message which is derived from JSON Schema.
+type ProtoMessage struct {
+	name          string
+	fields        []*ProtoField
+	messages      []*ProtoMessage
+	reserved      []int
+	reservedNames []string
+}
+
+// ProtoFieldNumbers represents the field numbers of a message in the previously generated schema,
+// which are kept so that the binary stays compatible with the clients built with the schema.
+type ProtoFieldNumbers struct {
+	numbers       map[string]int
+	reserved      []int
+	reservedNames []string
+}
+
+// ProtoField represents a field of ProtoMessage.
+type ProtoField struct {
+	key      string
+	name     string
+	number   int
+	kind     string
+	repeated bool
+	message  *ProtoMessage
+	enum     *Enum
+}
+
+// protoMessage returns the message of the records. The field numbers of the previous schema, which may be empty,
+// are kept, the new fields are numbered after them, and the numbers and names of the removed fields are reserved.
+func (m *MasterData) protoMessage(previousSchema string) (*ProtoMessage, error) {
+	schema, _ := m.schema(basicSchemaStrictness).Data().(map[string]interface{})
+	items, ok := schema["items"].(map[string]interface{})
+	if !ok || schema["type"] != "array" {
+		return nil, fmt.Errorf("Master data should be an array of objects: %v", m.fileName)
+	}
+	name := typeName(m.tableName())
+	return newProtoMessage(name, name, items, m.enums, parseProtoFieldNumbers(previousSchema))
+}
+
+func (m *MasterData) protoSchema(previousSchema string) (string, error) {
+	message, err := m.protoMessage(previousSchema)
+	if err != nil {
+		return "", err
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Generated by master from %v. DO NOT EDIT.\n", m.fileName)
+	b.WriteString("syntax = \"proto3\";\n\npackage master;\n\n")
+
+	var enumNames []string
+	for name := range m.enums {
+		enumNames = append(enumNames, name)
+	}
+	sort.Strings(enumNames)
+	for _, name := range enumNames {
+		writeProtoEnum(&b, m.enums[name])
+		b.WriteString("\n")
+	}
+
+	message.write(&b, "")
+	fmt.Fprintf(&b, "\nmessage %vList {\n  repeated %v items = 1;\n}\n", message.name, message.name)
+	return b.String(), nil
+}
+
+func (m *MasterData) protoBinary(previousSchema string) ([]byte, error) {
+	message, err := m.protoMessage(previousSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	for _, row := range m.records() {
+		encoded, err := message.encode(row)
+		if err != nil {
+			return nil, err
+		}
+		appendProtoBytes(&b, 1, encoded)
+	}
+	return b.Bytes(), nil
+}
+
+// newProtoMessage returns the message of the object schema. The path is the full name of the message,
+// e.g. "Items.Reward", which finds the field numbers of the previous schema.
+func newProtoMessage(name string, path string, schema map[string]interface{}, enums map[string]*Enum,
+	previous map[string]*ProtoFieldNumbers) (*ProtoMessage, error) {
+	if schema["type"] != "object" {
+		return nil, fmt.Errorf("Protocol Buffers message should be an object: %v", name)
+	}
+	properties, _ := schema["properties"].(map[string]interface{})
+
+	var keys []string
+	for key := range properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	numbers := previous[path]
+	if numbers == nil {
+		numbers = &ProtoFieldNumbers{numbers: make(map[string]int)}
+	}
+	nextNumber := 1
+	for _, number := range numbers.numbers {
+		if number >= nextNumber {
+			nextNumber = number + 1
+		}
+	}
+	for _, number := range numbers.reserved {
+		if number >= nextNumber {
+			nextNumber = number + 1
+		}
+	}
+
+	message := &ProtoMessage{name: name}
+	keysByName := make(map[string]string)
+	keysByMessageName := make(map[string]string)
+	for _, key := range keys {
+		field := &ProtoField{key: key, name: protoFieldName(key)}
+		if otherKey, ok := keysByName[field.name]; ok {
+			return nil, fmt.Errorf("Protocol Buffers field names of %v and %v conflict: %v.%v", otherKey, key, name, field.name)
+		}
+		keysByName[field.name] = key
+		if number, ok := numbers.numbers[field.name]; ok {
+			field.number = number
+		} else {
+			field.number = nextNumber
+			nextNumber++
+		}
+		propertySchema, _ := properties[key].(map[string]interface{})
+
+		if protoSchemaType(propertySchema) == "array" {
+			field.repeated = true
+			propertySchema, _ = propertySchema["items"].(map[string]interface{})
+			if propertySchema == nil {
+				propertySchema = map[string]interface{}{"type": "string"}
+			} else if protoSchemaType(propertySchema) == "array" {
+				return nil, fmt.Errorf("Nested array is not supported by Protocol Buffers: %v.%v", name, key)
+			}
+		}
+
+		if title, ok := propertySchema["title"].(string); ok && enums[title] != nil {
+			field.kind = title
+			field.enum = enums[title]
+			message.fields = append(message.fields, field)
+			continue
+		}
+
+		switch protoSchemaType(propertySchema) {
+		case "object":
+			if otherKey, ok := keysByMessageName[typeName(key)]; ok {
+				return nil, fmt.Errorf("Protocol Buffers message names of %v and %v conflict: %v.%v",
+					otherKey, key, name, typeName(key))
+			}
+			keysByMessageName[typeName(key)] = key
+			nestedMessage, err := newProtoMessage(typeName(key), path+"."+typeName(key), propertySchema, enums, previous)
+			if err != nil {
+				return nil, err
+			}
+			field.kind = nestedMessage.name
+			field.message = nestedMessage
+			message.messages = append(message.messages, nestedMessage)
+		case "string":
+			field.kind = "string"
+		case "boolean":
+			field.kind = "bool"
+		case "number":
+			field.kind = "double"
+		case "integer":
+			field.kind = "int64"
+		default:
+			return nil, fmt.Errorf("Unsupported type for Protocol Buffers: %v.%v %v", name, key, propertySchema["type"])
+		}
+		message.fields = append(message.fields, field)
+	}
+	sort.Slice(message.fields, func(i, j int) bool {
+		return message.fields[i].number < message.fields[j].number
+	})
+
+	message.reserved = append(message.reserved, numbers.reserved...)
+	for _, reservedName := range numbers.reservedNames {
+		if _, ok := keysByName[reservedName]; !ok {
+			message.reservedNames = append(message.reservedNames, reservedName)
+		}
+	}
+	for fieldName, number := range numbers.numbers {
+		if _, ok := keysByName[fieldName]; !ok {
+			message.reserved = append(message.reserved, number)
+			message.reservedNames = append(message.reservedNames, fieldName)
+		}
+	}
+	sort.Ints(message.reserved)
+	sort.Strings(message.reservedNames)
+	return message, nil
+}
+
+// protoSchemaType returns the type of the schema without null. Empty cells are null, e.g. ["string", "null"],
+// and they are omitted from the binary, so the nullable types are the types of the fields.
+func protoSchemaType(schema map[string]interface{}) interface{} {
+	typeNames, ok := schema["type"].([]interface{})
+	if !ok {
+		return schema["type"]
+	}
+	var nonNullTypeNames []interface{}
+	for _, typeName := range typeNames {
+		if typeName != "null" {
+			nonNullTypeNames = append(nonNullTypeNames, typeName)
+		}
+	}
+	if len(nonNullTypeNames) != 1 {
+		return schema["type"]
+	}
+	return nonNullTypeNames[0]
+}
+
+// parseProtoFieldNumbers returns the field numbers of the messages in the schema generated by master,
+// which are keyed by the full names of the messages.
+func parseProtoFieldNumbers(schema string) map[string]*ProtoFieldNumbers {
+	result := make(map[string]*ProtoFieldNumbers)
+	var path []string
+	var current *ProtoFieldNumbers
+	for _, line := range strings.Split(schema, "\n") {
+		if match := protoBlockPattern.FindStringSubmatch(line); match != nil {
+			path = append(path, match[2])
+			current = nil
+			if match[1] == "message" {
+				current = &ProtoFieldNumbers{numbers: make(map[string]int)}
+				result[strings.Join(path, ".")] = current
+			}
+		} else if strings.TrimSpace(line) == "}" && len(path) > 0 {
+			path = path[:len(path)-1]
+			current = result[strings.Join(path, ".")]
+		} else if current == nil {
+			continue
+		} else if match := protoFieldPattern.FindStringSubmatch(line); match != nil {
+			current.numbers[match[1]], _ = strconv.Atoi(match[2])
+		} else if match := protoReservedPattern.FindStringSubmatch(line); match != nil {
+			for _, value := range strings.Split(match[1], ",") {
+				value = strings.TrimSpace(value)
+				if number, err := strconv.Atoi(value); err == nil {
+					current.reserved = append(current.reserved, number)
+				} else {
+					current.reservedNames = append(current.reservedNames, strings.Trim(value, `"`))
+				}
+			}
+		}
+	}
+	return result
+}
+
+// writeProtoEnum writes the enum whose values are prefixed by the enum name,
+// because proto3 enum values are scoped in the package and the first value should be zero.
+func writeProtoEnum(b *bytes.Buffer, enum *Enum) {
+	prefix := strings.ToUpper(protoFieldName(snakeCase(enum.name))) + "_"
+	labels := enum.labels()
+	seen := make(map[int]bool)
+
+	fmt.Fprintf(b, "enum %v {\n", enum.name)
+	for _, label := range labels {
+		if seen[enum.values[label]] {
+			b.WriteString("  option allow_alias = true;\n")
+			break
+		}
+		seen[enum.values[label]] = true
+	}
+	if !seen[0] {
+		fmt.Fprintf(b, "  %vUNSPECIFIED = 0;\n", prefix)
+	}
+	for _, label := range labels {
+		fmt.Fprintf(b, "  %v%v = %v;\n", prefix, strings.ToUpper(protoFieldName(label)), enum.values[label])
+	}
+	b.WriteString("}\n")
+}
+
+func (m *ProtoMessage) write(b *bytes.Buffer, indent string) {
+	fmt.Fprintf(b, "%vmessage %v {\n", indent, m.name)
+	for _, message := range m.messages {
+		message.write(b, indent+"  ")
+		b.WriteString("\n")
+	}
+	for _, field := range m.fields {
+		b.WriteString(indent + "  ")
+		if field.repeated {
+			b.WriteString("repeated ")
+		}
+		fmt.Fprintf(b, "%v %v = %v;\n", field.kind, field.name, field.number)
+	}
+	if len(m.reserved) > 0 {
+		numbers := make([]string, len(m.reserved))
+		for i, number := range m.reserved {
+			numbers[i] = strconv.Itoa(number)
+		}
+		fmt.Fprintf(b, "%v  reserved %v;\n", indent, strings.Join(numbers, ", "))
+	}
+	if len(m.reservedNames) > 0 {
+		names := make([]string, len(m.reservedNames))
+		for i, name := range m.reservedNames {
+			names[i] = strconv.Quote(name)
+		}
+		fmt.Fprintf(b, "%v  reserved %v;\n", indent, strings.Join(names, ", "))
+	}
+	fmt.Fprintf(b, "%v}\n", indent)
+}
+
+func (m *ProtoMessage) encode(value interface{}) ([]byte, error) {
+	valueAsMap, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Value should be an object for %v message: %v", m.name, value)
+	}
+
+	var b bytes.Buffer
+	for _, field := range m.fields {
+		fieldValue, ok := valueAsMap[field.key]
+		if !ok || fieldValue == nil {
+			continue
+		}
+		if !field.repeated {
+			if err := field.encode(&b, fieldValue); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		values, ok := fieldValue.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("Value should be an array for %v field: %v", field.key, fieldValue)
+		}
+		if field.message != nil || field.kind == "string" {
+			for _, v := range values {
+				if err := field.encode(&b, v); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+
+		var packed bytes.Buffer
+		for _, v := range values {
+			if err := field.encodeScalar(&packed, v); err != nil {
+				return nil, err
+			}
+		}
+		appendProtoBytes(&b, field.number, packed.Bytes())
+	}
+	return b.Bytes(), nil
+}
+
+func (f *ProtoField) encode(b *bytes.Buffer, value interface{}) error {
+	if f.message != nil {
+		encoded, err := f.message.encode(value)
+		if err != nil {
+			return err
+		}
+		appendProtoBytes(b, f.number, encoded)
+		return nil
+	}
+	if f.kind == "string" {
+		valueAsString, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("Value should be a string for %v field: %v", f.key, value)
+		}
+		appendProtoBytes(b, f.number, []byte(valueAsString))
+		return nil
+	}
+
+	switch f.kind {
+	case "double":
+		appendProtoVarint(b, uint64(f.number)<<3|protoWireFixed64)
+	default:
+		appendProtoVarint(b, uint64(f.number)<<3|protoWireVarint)
+	}
+	return f.encodeScalar(b, value)
+}
+
+func (f *ProtoField) encodeScalar(b *bytes.Buffer, value interface{}) error {
+	kind := f.kind
+	if f.enum != nil {
+		kind = "int64"
+	}
+
+	switch kind {
+	case "bool":
+		valueAsBool, ok := value.(bool)
+		if !ok {
+			return fmt.Errorf("Value should be a boolean for %v field: %v", f.key, value)
+		}
+		if valueAsBool {
+			appendProtoVarint(b, 1)
+		} else {
+			appendProtoVarint(b, 0)
+		}
+	case "double", "int64":
+		valueAsFloat, ok := toFloat64(value)
+		if !ok {
+			return fmt.Errorf("Value should be a number for %v field: %v", f.key, value)
+		}
+		if kind == "int64" {
+			appendProtoVarint(b, uint64(int64(valueAsFloat)))
+		} else {
+			var fixed [8]byte
+			binary.LittleEndian.PutUint64(fixed[:], math.Float64bits(valueAsFloat))
+			b.Write(fixed[:])
+		}
+	}
+	return nil
+}
+
+func appendProtoVarint(b *bytes.Buffer, value uint64) {
+	var varint [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(varint[:], value)
+	b.Write(varint[:n])
+}
+
+func appendProtoBytes(b *bytes.Buffer, number int, data []byte) {
+	appendProtoVarint(b, uint64(number)<<3|protoWireBytes)
+	appendProtoVarint(b, uint64(len(data)))
+	b.Write(data)
+}
+
+func protoFieldName(key string) string {
+	name := strings.Trim(protoInvalidCharPattern.ReplaceAllString(key, "_"), "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "f_" + name
+	}
+	return name
+}
+
+func snakeCase(name string) string {
+	var b bytes.Buffer
+	for i, r := range name {
+		if i > 0 && r >= 'A' && r <= 'Z' && !(name[i-1] >= 'A' && name[i-1] <= 'Z') {
+			b.WriteRune('_')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func toFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	}
+	return 0, false
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestProtobuf(t *testing.T) {
+	Convey("protobuf", t, func() {
+		jsonText := `[{ "id": 1, "name": "a", "tags": ["x"], "flags": [true, false], "user": { "age": 2 } }]`
+
+		Convey("MasterData#protoSchema", func() {
+			Convey("should return the Protocol Buffers schema", func() {
+				masterData, _ := newMasterData("user_items.json", jsonText, 0)
+				actual, err := masterData.protoSchema("")
+				So(err, ShouldBeNil)
+				So(actual, ShouldEqual, `// Generated by master from user_items.json. DO NOT EDIT.
+syntax = "proto3";
+
+package master;
+
+message UserItems {
+  message User {
+    double age = 1;
+  }
+
+  repeated bool flags = 1;
+  double id = 2;
+  string name = 3;
+  repeated string tags = 4;
+  User user = 5;
+}
+
+message UserItemsList {
+  repeated UserItems items = 1;
+}
+`)
+			})
+
+			Convey("with enum columns", func() {
+				Convey("should return enum declarations", func() {
+					options := &CSVTableOptions{enums: map[string]*Enum{
+						"ItemType": &Enum{name: "ItemType", values: map[string]int{"weapon": 1, "armor": 2}},
+					}}
+					csvTable, _ := newCSVTableWithOptions("items.csv", "utf-8", []byte("id,type:ItemType\n1,armor"), options)
+					masterData, _ := newMasterDataFromCSV(csvTable, 0)
+					actual, err := masterData.protoSchema("")
+					So(err, ShouldBeNil)
+					So(actual, ShouldContainSubstring, `enum ItemType {
+  ITEM_TYPE_UNSPECIFIED = 0;
+  ITEM_TYPE_WEAPON = 1;
+  ITEM_TYPE_ARMOR = 2;
+}
+
+message Items {
+  double id = 1;
+  ItemType type = 2;
+}
+`)
+					binary, err := masterData.protoBinary("")
+					So(err, ShouldBeNil)
+					So(binary, ShouldResemble, []byte{
+						0x0a, 0x0b,
+						0x09, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf0, 0x3f,
+						0x10, 0x02,
+					})
+				})
+			})
+
+			Convey("with the previous schema", func() {
+				Convey("should keep the field numbers and reserve the removed fields", func() {
+					previous, _ := newMasterData("user_items.json", jsonText, 0)
+					previousSchema, err := previous.protoSchema("")
+					So(err, ShouldBeNil)
+
+					masterData, _ := newMasterData("user_items.json",
+						`[{ "id": 1, "count": 2, "tags": ["x"], "flags": [true], "user": { "age": 2, "level": 3 } }]`, 0)
+					actual, err := masterData.protoSchema(previousSchema)
+					So(err, ShouldBeNil)
+					So(actual, ShouldContainSubstring, `message UserItems {
+  message User {
+    double age = 1;
+    double level = 2;
+  }
+
+  repeated bool flags = 1;
+  double id = 2;
+  repeated string tags = 4;
+  User user = 5;
+  double count = 6;
+  reserved 3;
+  reserved "name";
+}
+`)
+
+					Convey("should keep the reserved fields in the next schema", func() {
+						next, err := masterData.protoSchema(actual)
+						So(err, ShouldBeNil)
+						So(next, ShouldEqual, actual)
+					})
+
+					Convey("should encode the binary by the field numbers", func() {
+						masterData, _ := newMasterData("user_items.json", `[{ "count": 1 }]`, 0)
+						binary, err := masterData.protoBinary(actual)
+						So(err, ShouldBeNil)
+						So(binary[2], ShouldEqual, 6<<3|protoWireFixed64)
+					})
+				})
+			})
+
+			Convey("with conflicting field names", func() {
+				Convey("should return an error", func() {
+					masterData, _ := newMasterData("foo.json", `[{ "a_b": 1, "a.b": 2 }]`, 0)
+					_, err := masterData.protoSchema("")
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldEqual, "Protocol Buffers field names of a.b and a_b conflict: Foo.a_b")
+				})
+			})
+
+			Convey("with conflicting nested message names", func() {
+				Convey("should return an error", func() {
+					masterData, _ := newMasterData("foo.json", `[{ "a_b": { "c": 1 }, "aB": { "d": 2 } }]`, 0)
+					_, err := masterData.protoSchema("")
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldEqual, "Protocol Buffers message names of aB and a_b conflict: Foo.AB")
+				})
+			})
+
+			Convey("with nullable columns", func() {
+				Convey("should return the fields of the types without null", func() {
+					csvTable, _ := newCSVTable("events.csv", "utf-8",
+						[]byte("id,start_at:datetime\n1,2020-01-01T00:00:00Z\n2,"))
+					masterData, _ := newMasterDataFromCSV(csvTable, 0)
+					actual, err := masterData.protoSchema("")
+					So(err, ShouldBeNil)
+					So(actual, ShouldContainSubstring, "  string start_at = 2;\n")
+
+					binary, err := masterData.protoBinary("")
+					So(err, ShouldBeNil)
+					So(binary, ShouldNotBeEmpty)
+				})
+			})
+
+			Convey("with nested array data", func() {
+				Convey("should return an error", func() {
+					masterData, _ := newMasterData("foo.json", `[{ "items": [[1]] }]`, 0)
+					_, err := masterData.protoSchema("")
+					So(err, ShouldNotBeNil)
+				})
+			})
+		})
+
+		Convey("MasterData#protoBinary", func() {
+			Convey("should return the Protocol Buffers binary", func() {
+				masterData, _ := newMasterData("user_items.json", jsonText, 0)
+				actual, err := masterData.protoBinary("")
+				So(err, ShouldBeNil)
+				So(actual, ShouldResemble, []byte{
+					0x0a, 0x1e,
+					0x0a, 0x02, 0x01, 0x00,
+					0x11, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf0, 0x3f,
+					0x1a, 0x01, 'a',
+					0x22, 0x01, 'x',
+					0x2a, 0x09, 0x09, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x40,
+				})
+			})
+		})
+
+		Convey(".protoFieldName", func() {
+			Convey("should return a valid field name", func() {
+				So(protoFieldName("voice_actors"), ShouldEqual, "voice_actors")
+				So(protoFieldName("-1"), ShouldEqual, "f_1")
+			})
+		})
+	})
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Relation represents a child table which is embedded into the parent table, which is defined in relations.yaml.
+// Each parent record has the child records whose foreign key is the key of the parent record.
+type Relation struct {
+	Parent     string `yaml:"parent"`
+	Child      string `yaml:"child"`
+	Key        string `yaml:"key"`
+	ForeignKey string `yaml:"foreign_key"`
+	As         string `yaml:"as"`
+}
+
+func newRelations(data []byte) ([]*Relation, error) {
+	var relations []*Relation
+	if err := yaml.UnmarshalStrict(data, &relations); err != nil {
+		return nil, err
+	}
+
+	for _, relation := range relations {
+		if relation.Parent == "" || relation.Child == "" || relation.ForeignKey == "" {
+			return nil, fmt.Errorf("Relation should have parent, child and foreign_key: %+v", *relation)
+		}
+		if relation.Key == "" {
+			relation.Key = "id"
+		}
+		if relation.As == "" {
+			relation.As = relation.Child
+		}
+	}
+	return relations, nil
+}
+
+// join embeds the child records into the parent records, and returns the error if some child records have no parent.
+func (r *Relation) join(parent *MasterData, child *MasterData) error {
+	parentRecords := make(map[string]map[string]interface{})
+	for _, record := range parent.records() {
+		recordAsMap, ok := record.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("Record of %v should be an object: %v", r.Parent, record)
+		}
+		key, ok := recordAsMap[r.Key]
+		if !ok {
+			return fmt.Errorf("Record of %v has no key: %v", r.Parent, r.Key)
+		}
+		if _, ok := recordAsMap[r.As]; ok {
+			return fmt.Errorf("Record of %v already has %v", r.Parent, r.As)
+		}
+		if _, ok := parentRecords[fmt.Sprint(key)]; ok {
+			return fmt.Errorf("Records of %v have the duplicate key: %v %v", r.Parent, r.Key, key)
+		}
+		recordAsMap[r.As] = []interface{}{}
+		parentRecords[fmt.Sprint(key)] = recordAsMap
+	}
+
+	var orphans []string
+	for i, record := range child.records() {
+		recordAsMap, ok := record.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("Record of %v should be an object: %v", r.Child, record)
+		}
+		foreignKey := fmt.Sprint(recordAsMap[r.ForeignKey])
+		parentRecord, ok := parentRecords[foreignKey]
+		if !ok {
+			orphans = append(orphans, fmt.Sprintf("%v: %v %v is not found in %v", i, r.ForeignKey, foreignKey, r.Parent))
+			continue
+		}
+
+		// The child record is not copied, so grandchild records which are joined later are also embedded.
+		delete(recordAsMap, r.ForeignKey)
+		parentRecord[r.As] = append(parentRecord[r.As].([]interface{}), recordAsMap)
+	}
+	if len(orphans) > 0 {
+		return fmt.Errorf("Child records of %v have no parent:\n  %v", r.Child, strings.Join(orphans, "\n  "))
+	}
+
+	embedColumnSchemas(parent, child, r.As+"."+multiRowArrayKey)
+	return nil
+}
+
+// embedColumnSchemas types the typed columns of the child table also in the parent table,
+// whose column names have the prefix, e.g. "items.*".
+func embedColumnSchemas(parent *MasterData, child *MasterData, prefix string) {
+	for columnName, schema := range child.columnSchemas {
+		if parent.columnSchemas == nil {
+			parent.columnSchemas = make(map[string]map[string]interface{})
+		}
+		parent.columnSchemas[prefix+"."+columnName] = schema
+	}
+	for name, enum := range child.enums {
+		if parent.enums == nil {
+			parent.enums = make(map[string]*Enum)
+		}
+		parent.enums[name] = enum
+	}
+}
+
+// joinMasterData embeds the child tables into the parent tables in order of the relations,
+// and returns the master data list without the child tables.
+func joinMasterData(masterDataList []*MasterData, relations []*Relation) ([]*MasterData, error) {
+	tables := make(map[string]*MasterData)
+	for _, masterData := range masterDataList {
+		tables[masterData.tableName()] = masterData
+	}
+
+	// The relations which embed the tables, to type the columns of grandchild tables which are joined later.
+	embeddings := make(map[string]*Relation)
+	children := make(map[string]bool)
+	for _, relation := range relations {
+		parent, child := tables[relation.Parent], tables[relation.Child]
+		if parent == nil {
+			continue
+		}
+		if child == nil {
+			return nil, fmt.Errorf("Child table of %v is not found: %v", relation.Parent, relation.Child)
+		}
+		if err := relation.join(parent, child); err != nil {
+			return nil, err
+		}
+		prefix := relation.As + "." + multiRowArrayKey
+		visited := map[string]bool{relation.Parent: true}
+		for embedding := embeddings[relation.Parent]; embedding != nil; embedding = embeddings[embedding.Parent] {
+			if visited[embedding.Parent] {
+				break
+			}
+			visited[embedding.Parent] = true
+			prefix = embedding.As + "." + multiRowArrayKey + "." + prefix
+			embedColumnSchemas(tables[embedding.Parent], child, prefix)
+		}
+		embeddings[relation.Child] = relation
+		children[relation.Child] = true
+	}
+
+	var result []*MasterData
+	for _, masterData := range masterDataList {
+		if !children[masterData.tableName()] {
+			result = append(result, masterData)
+		}
+	}
+	return result, nil
+}
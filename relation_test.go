@@ -0,0 +1,78 @@
+package main
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestRelation(t *testing.T) {
+	Convey("Relation", t, func() {
+		Convey(".newRelations", func() {
+			Convey("should return Relations which have the default keys", func() {
+				relations, err := newRelations([]byte("- parent: characters\n  child: character_skills\n  foreign_key: character_id\n"))
+				So(err, ShouldBeNil)
+				So(relations, ShouldResemble, []*Relation{
+					&Relation{Parent: "characters", Child: "character_skills", Key: "id", ForeignKey: "character_id", As: "character_skills"},
+				})
+			})
+
+			Convey("without foreign_key", func() {
+				Convey("should return error", func() {
+					_, err := newRelations([]byte("- parent: characters\n  child: character_skills\n"))
+					So(err, ShouldNotBeNil)
+				})
+			})
+		})
+
+		Convey(".joinMasterData", func() {
+			relations := []*Relation{
+				&Relation{Parent: "characters", Child: "character_skills", Key: "id", ForeignKey: "character_id", As: "skills"},
+			}
+			characters, _ := newMasterData("characters.json", `[{ "id": 1 }, { "id": 2 }]`, 0)
+
+			Convey("should embed the child records into the parent records", func() {
+				skills, _ := newMasterData("character_skills.json",
+					`[{ "character_id": 1, "name": "a" }, { "character_id": 1, "name": "b" }]`, 0)
+				actual, err := joinMasterData([]*MasterData{characters, skills}, relations)
+				So(err, ShouldBeNil)
+				So(actual, ShouldResemble, []*MasterData{characters})
+				So(characters.json(), ShouldEqual, `[{"id":1,"skills":[{"name":"a"},{"name":"b"}]},{"id":2,"skills":[]}]`)
+			})
+
+			Convey("with child records which have no parent", func() {
+				Convey("should return error", func() {
+					skills, _ := newMasterData("character_skills.json", `[{ "character_id": 1 }, { "character_id": 3 }]`, 0)
+					_, err := joinMasterData([]*MasterData{characters, skills}, relations)
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldEqual,
+						"Child records of character_skills have no parent:\n  1: character_id 3 is not found in characters")
+				})
+			})
+
+			Convey("with parent records which have the duplicate key", func() {
+				Convey("should return error", func() {
+					characters, _ := newMasterData("characters.json", `[{ "id": 1 }, { "id": 1 }]`, 0)
+					skills, _ := newMasterData("character_skills.json", `[{ "character_id": 1 }]`, 0)
+					_, err := joinMasterData([]*MasterData{characters, skills}, relations)
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldEqual, "Records of characters have the duplicate key: id 1")
+				})
+			})
+
+			Convey("with a grandchild table which is joined after the child table", func() {
+				Convey("should type the columns of the grandchild table in the parent table", func() {
+					relations := append(relations,
+						&Relation{Parent: "character_skills", Child: "skill_effects", Key: "id", ForeignKey: "skill_id", As: "effects"})
+					skills, _ := newMasterData("character_skills.json", `[{ "id": 10, "character_id": 1 }]`, 0)
+					effects, _ := newMasterData("skill_effects.json", `[{ "skill_id": 10, "start_at": "2026-10-01T00:00:00Z" }]`, 0)
+					effects.columnSchemas = map[string]map[string]interface{}{"start_at": {"format": "date-time"}}
+					_, err := joinMasterData([]*MasterData{characters, skills, effects}, relations)
+					So(err, ShouldBeNil)
+					So(characters.columnSchemas, ShouldResemble, map[string]map[string]interface{}{
+						"skills.*.effects.*.start_at": {"format": "date-time"},
+					})
+				})
+			})
+		})
+	})
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/Knetic/govaluate"
+	"gopkg.in/yaml.v2"
+)
+
+const ruleApproxEpsilon = 1e-9
+
+// Rules represents custom validation rules of a table, which cannot be expressed in JSON Schema.
+type Rules struct {
+	Rows  []*Rule `yaml:"rows"`
+	Table []*Rule `yaml:"table"`
+}
+
+// Rule represents an assertion by an expression, which should be evaluated to true.
+type Rule struct {
+	Name   string `yaml:"name"`
+	Assert string `yaml:"assert"`
+}
+
+// ruleArray represents an array value in expressions.
+// govaluate spreads []interface{} into function arguments, so arrays are wrapped by this type.
+type ruleArray []interface{}
+
+// ruleParameters resolves the parameters of expressions, e.g. [items.0.count], by the record.
+type ruleParameters struct {
+	record interface{}
+}
+
+func newRules(data []byte) (*Rules, error) {
+	rules := &Rules{}
+	if err := yaml.UnmarshalStrict(data, rules); err != nil {
+		return nil, err
+	}
+
+	functions := ruleFunctions(nil)
+	for _, rule := range append(append([]*Rule{}, rules.Rows...), rules.Table...) {
+		if rule.Assert == "" {
+			return nil, fmt.Errorf("Rule should have an assert expression: %v", rule.Name)
+		}
+		if _, err := govaluate.NewEvaluableExpressionWithFunctions(rule.Assert, functions); err != nil {
+			return nil, fmt.Errorf("Invalid expression of rule: %v\n%v", rule.Assert, err)
+		}
+	}
+	return rules, nil
+}
+
+// validate evaluates the rules of each row and the whole table,
+// and returns the error which has all unsatisfied rules.
+func (r *Rules) validate(records []interface{}) error {
+	functions := ruleFunctions(records)
+	var messages []string
+
+	for _, rule := range r.Rows {
+		expression, err := govaluate.NewEvaluableExpressionWithFunctions(rule.Assert, functions)
+		if err != nil {
+			return err
+		}
+		for i, record := range records {
+			if message := rule.evaluate(expression, record); message != "" {
+				messages = append(messages, fmt.Sprintf("%v: %v", i, message))
+			}
+		}
+	}
+	for _, rule := range r.Table {
+		expression, err := govaluate.NewEvaluableExpressionWithFunctions(rule.Assert, functions)
+		if err != nil {
+			return err
+		}
+		if message := rule.evaluate(expression, map[string]interface{}{}); message != "" {
+			messages = append(messages, fmt.Sprintf("%v: %v", instancePathName(""), message))
+		}
+	}
+
+	if len(messages) > 0 {
+		return validationError(messages)
+	}
+	return nil
+}
+
+// evaluate returns the message why the rule is not satisfied, or an empty string.
+func (r *Rule) evaluate(expression *govaluate.EvaluableExpression, record interface{}) string {
+	result, err := expression.Eval(ruleParameters{record: record})
+	if err != nil {
+		return fmt.Sprintf("%v (%v)", r.description(), err)
+	}
+	if result != true {
+		return r.description()
+	}
+	return ""
+}
+
+func (r *Rule) description() string {
+	if r.Name == "" {
+		return "Rule is not satisfied: " + r.Assert
+	}
+	return fmt.Sprintf("Rule is not satisfied: %v (%v)", r.Name, r.Assert)
+}
+
+func (p ruleParameters) Get(name string) (interface{}, error) {
+	value, ok := lookupRuleValue(p.record, name)
+	if !ok {
+		return nil, fmt.Errorf("No such column: %v", name)
+	}
+	return value, nil
+}
+
+func lookupRuleValue(record interface{}, path string) (interface{}, bool) {
+	value, ok := lookupPath(record, path)
+	if !ok {
+		return nil, false
+	}
+	switch v := value.(type) {
+	case int:
+		// Empty numeric cells are converted into int, but expressions can only handle float64.
+		return float64(v), true
+	case []interface{}:
+		return ruleArray(v), true
+	}
+	return value, true
+}
+
+// ruleFunctions returns the functions which can be used in expressions.
+// column(path) returns the values of the path in all records.
+func ruleFunctions(records []interface{}) map[string]govaluate.ExpressionFunction {
+	return map[string]govaluate.ExpressionFunction{
+		"column": func(args ...interface{}) (interface{}, error) {
+			if len(args) != 1 {
+				return nil, errors.New("column() should have 1 argument")
+			}
+			path, ok := args[0].(string)
+			if !ok {
+				return nil, fmt.Errorf("column() should have a column name: %v", args[0])
+			}
+			values := make(ruleArray, 0, len(records))
+			for _, record := range records {
+				if value, ok := lookupRuleValue(record, path); ok {
+					values = append(values, value)
+				}
+			}
+			return values, nil
+		},
+		"len": func(args ...interface{}) (interface{}, error) {
+			if len(args) != 1 {
+				return nil, errors.New("len() should have 1 argument")
+			}
+			switch v := args[0].(type) {
+			case ruleArray:
+				return float64(len(v)), nil
+			case map[string]interface{}:
+				return float64(len(v)), nil
+			case string:
+				return float64(len([]rune(v))), nil
+			}
+			return nil, fmt.Errorf("len() should have an array, an object or a string: %v", args[0])
+		},
+		"sum": func(args ...interface{}) (interface{}, error) {
+			numbers, err := ruleNumbers("sum", args)
+			if err != nil {
+				return nil, err
+			}
+			result := 0.0
+			for _, number := range numbers {
+				result += number
+			}
+			return result, nil
+		},
+		"min": func(args ...interface{}) (interface{}, error) {
+			numbers, err := ruleNumbers("min", args)
+			if err != nil {
+				return nil, err
+			}
+			if len(numbers) == 0 {
+				return nil, errors.New("min() should have at least 1 number")
+			}
+			result := numbers[0]
+			for _, number := range numbers[1:] {
+				result = math.Min(result, number)
+			}
+			return result, nil
+		},
+		"max": func(args ...interface{}) (interface{}, error) {
+			numbers, err := ruleNumbers("max", args)
+			if err != nil {
+				return nil, err
+			}
+			if len(numbers) == 0 {
+				return nil, errors.New("max() should have at least 1 number")
+			}
+			result := numbers[0]
+			for _, number := range numbers[1:] {
+				result = math.Max(result, number)
+			}
+			return result, nil
+		},
+		"unique": func(args ...interface{}) (interface{}, error) {
+			seen := make(map[string]bool)
+			for _, value := range flattenRuleArgs(args) {
+				key := fmt.Sprintf("%T:%v", value, value)
+				if seen[key] {
+					return false, nil
+				}
+				seen[key] = true
+			}
+			return true, nil
+		},
+		"approx": func(args ...interface{}) (interface{}, error) {
+			numbers, err := ruleNumbers("approx", args)
+			if err != nil {
+				return nil, err
+			}
+			if len(numbers) != 2 {
+				return nil, errors.New("approx() should have 2 numbers")
+			}
+			return math.Abs(numbers[0]-numbers[1]) < ruleApproxEpsilon, nil
+		},
+	}
+}
+
+func ruleNumbers(name string, args []interface{}) ([]float64, error) {
+	var numbers []float64
+	for _, value := range flattenRuleArgs(args) {
+		number, ok := toFloat64(value)
+		if !ok {
+			return nil, fmt.Errorf("%v() should have numbers: %v", name, value)
+		}
+		numbers = append(numbers, number)
+	}
+	return numbers, nil
+}
+
+func flattenRuleArgs(args []interface{}) []interface{} {
+	var result []interface{}
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case ruleArray:
+			result = append(result, flattenRuleArgs(v)...)
+		case []interface{}:
+			result = append(result, flattenRuleArgs(v)...)
+		default:
+			result = append(result, arg)
+		}
+	}
+	return result
+}
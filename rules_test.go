@@ -0,0 +1,85 @@
+package main
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestRules(t *testing.T) {
+	Convey("Rules", t, func() {
+		records := []interface{}{
+			map[string]interface{}{
+				"id":         1.0,
+				"start_at":   "2017-01-01",
+				"end_at":     "2017-01-31",
+				"drop_rates": []interface{}{0.1, 0.2, 0.7},
+				"items":      []interface{}{map[string]interface{}{"count": 3.0}},
+			},
+			map[string]interface{}{
+				"id":         2.0,
+				"start_at":   "2017-02-01",
+				"end_at":     "2017-01-01",
+				"drop_rates": []interface{}{0.5, 0.2, 0},
+				"items":      []interface{}{map[string]interface{}{"count": 0}},
+			},
+		}
+
+		Convey("newRules", func() {
+			Convey("with an invalid expression", func() {
+				Convey("should return error", func() {
+					_, err := newRules([]byte("rows:\n  - assert: \"id ==\"\n"))
+					So(err, ShouldNotBeNil)
+				})
+			})
+
+			Convey("with an unknown key", func() {
+				Convey("should return error", func() {
+					_, err := newRules([]byte("rows:\n  - expr: \"id > 0\"\n"))
+					So(err, ShouldNotBeNil)
+				})
+			})
+		})
+
+		Convey("#validate", func() {
+			Convey("with satisfied rules", func() {
+				Convey("should return no error", func() {
+					rules, err := newRules([]byte(`
+rows:
+  - assert: "id > 0 && [items.0.count] >= 0"
+table:
+  - assert: "unique(column('id')) && len(column('id')) == 2"
+  - assert: "sum(column('id')) == 3 && max(column('id')) == 2"
+`))
+					So(err, ShouldBeNil)
+					So(rules.validate(records), ShouldBeNil)
+				})
+			})
+
+			Convey("with unsatisfied rules", func() {
+				Convey("should return error with row numbers", func() {
+					rules, err := newRules([]byte(`
+rows:
+  - name: drop rates sum to 1
+    assert: "approx(sum(drop_rates), 1)"
+  - assert: "start_at < end_at"
+table:
+  - assert: "min(column('items.0.count')) > 0"
+`))
+					So(err, ShouldBeNil)
+					So(rules.validate(records).Error(), ShouldEqual, "The JSON data is not valid:\n"+
+						"  1: Rule is not satisfied: drop rates sum to 1 (approx(sum(drop_rates), 1))\n"+
+						"  1: Rule is not satisfied: start_at < end_at\n"+
+						"  (root): Rule is not satisfied: min(column('items.0.count')) > 0\n")
+				})
+			})
+
+			Convey("with an unknown column", func() {
+				Convey("should return error", func() {
+					rules, err := newRules([]byte("rows:\n  - assert: \"[items.1.count] > 0\"\n"))
+					So(err, ShouldBeNil)
+					So(rules.validate(records).Error(), ShouldContainSubstring, "No such column: items.1.count")
+				})
+			})
+		})
+	})
+}
@@ -0,0 +1,447 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jeffail/gabs"
+)
+
+// SchemaStrictness represents which constraints are inferred in JSON Schema.
+type SchemaStrictness int
+
+const (
+	basicSchemaStrictness SchemaStrictness = iota
+	standardSchemaStrictness
+	strictSchemaStrictness
+)
+
+const enumMaxValues = 10
+
+var schemaStrictnessNames = map[string]SchemaStrictness{
+	"basic":    basicSchemaStrictness,
+	"standard": standardSchemaStrictness,
+	"strict":   strictSchemaStrictness,
+}
+
+// stringPatternHints are the candidates of the pattern keyword, from specific to general.
+var stringPatternHints = []string{
+	"^#[0-9A-Fa-f]{6}([0-9A-Fa-f]{2})?$",
+	"^https?://[^\\s]+$",
+	"^[A-Z][A-Z0-9_]*$",
+	"^[a-z][a-z0-9_]*$",
+	"^[A-Za-z0-9_./-]+$",
+}
+
+var stringPatternHintPatterns = compileStringPatternHints()
+
+func compileStringPatternHints() []*regexp.Regexp {
+	patterns := make([]*regexp.Regexp, len(stringPatternHints))
+	for i, pattern := range stringPatternHints {
+		patterns[i] = regexp.MustCompile(pattern)
+	}
+	return patterns
+}
+
+func parseSchemaStrictness(value string) (SchemaStrictness, error) {
+	strictness, ok := schemaStrictnessNames[value]
+	if !ok {
+		return basicSchemaStrictness, fmt.Errorf("Unknown schema strictness: %v", value)
+	}
+	return strictness, nil
+}
+
+// SchemaNode accumulates types of JSON values at the same position to infer JSON Schema.
+// The root node is the array of the records, whose length is not constrained.
+type SchemaNode struct {
+	isRoot         bool
+	types          map[string]bool
+	objectCount    int
+	properties     map[string]*SchemaNode
+	propertyCounts map[string]int
+	items          *SchemaNode
+
+	minimum        float64
+	maximum        float64
+	numberCount    int
+	stringCount    int
+	maxLength      int
+	stringValues   map[string]bool
+	hasManyStrings bool
+	hasEmptyString bool
+	patternMatches []bool
+	arrayCount     int
+	minItems       int
+	maxItems       int
+	uniqueItems    bool
+}
+
+func newSchemaNode() *SchemaNode {
+	return &SchemaNode{
+		types:          make(map[string]bool),
+		properties:     make(map[string]*SchemaNode),
+		propertyCounts: make(map[string]int),
+		stringValues:   make(map[string]bool),
+		uniqueItems:    true,
+	}
+}
+
+func getJSONSchema(obj interface{}, strictness SchemaStrictness) *gabs.Container {
+	node := newSchemaNode()
+	node.isRoot = true
+	node.add(obj)
+	schema, _ := gabs.Consume(node.schema(strictness))
+	return schema
+}
+
+func (n *SchemaNode) add(value interface{}) {
+	switch v := value.(type) {
+	case []map[string]interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = item
+		}
+		n.addArray(items)
+	case []interface{}:
+		n.addArray(v)
+	case map[string]interface{}:
+		n.types["object"] = true
+		n.objectCount++
+		for key, propertyValue := range v {
+			property, ok := n.properties[key]
+			if !ok {
+				property = newSchemaNode()
+				n.properties[key] = property
+			}
+			property.add(propertyValue)
+			n.propertyCounts[key]++
+		}
+	case string:
+		n.addString(v)
+	case bool:
+		n.types["boolean"] = true
+	case nil:
+		n.types["null"] = true
+	default:
+		n.types["number"] = true
+		if number, ok := toFloat64(v); ok {
+			if n.numberCount == 0 || number < n.minimum {
+				n.minimum = number
+			}
+			if n.numberCount == 0 || number > n.maximum {
+				n.maximum = number
+			}
+		}
+		n.numberCount++
+	}
+}
+
+func (n *SchemaNode) addArray(items []interface{}) {
+	n.types["array"] = true
+	if n.arrayCount == 0 || len(items) < n.minItems {
+		n.minItems = len(items)
+	}
+	if n.arrayCount == 0 || len(items) > n.maxItems {
+		n.maxItems = len(items)
+	}
+	n.arrayCount++
+
+	seen := make(map[string]bool)
+	for _, item := range items {
+		if n.items == nil {
+			n.items = newSchemaNode()
+		}
+		n.items.add(item)
+
+		encoded, _ := json.Marshal(item)
+		if seen[string(encoded)] {
+			n.uniqueItems = false
+		}
+		seen[string(encoded)] = true
+	}
+}
+
+func (n *SchemaNode) addString(value string) {
+	n.types["string"] = true
+	if length := utf8.RuneCountInString(value); length > n.maxLength {
+		n.maxLength = length
+	}
+	if !n.stringValues[value] {
+		if len(n.stringValues) < enumMaxValues {
+			n.stringValues[value] = true
+		} else {
+			n.hasManyStrings = true
+		}
+	}
+	if value == "" {
+		n.hasEmptyString = true
+	} else {
+		if n.patternMatches == nil {
+			n.patternMatches = make([]bool, len(stringPatternHintPatterns))
+			for i := range n.patternMatches {
+				n.patternMatches[i] = true
+			}
+		}
+		for i, pattern := range stringPatternHintPatterns {
+			n.patternMatches[i] = n.patternMatches[i] && pattern.MatchString(value)
+		}
+	}
+	n.stringCount++
+}
+
+func (n *SchemaNode) schema(strictness SchemaStrictness) map[string]interface{} {
+	var types []string
+	hasStructuredType := false
+	for t := range n.types {
+		types = append(types, t)
+		hasStructuredType = hasStructuredType || t == "object" || t == "array"
+	}
+	sort.Strings(types)
+
+	switch {
+	case len(types) == 0:
+		return map[string]interface{}{}
+	case len(types) == 1:
+		return n.schemaOf(types[0], strictness)
+	case !hasStructuredType:
+		schema := make(map[string]interface{})
+		typeNames := make([]interface{}, len(types))
+		for i, t := range types {
+			typeNames[i] = t
+			for key, value := range n.schemaOf(t, strictness) {
+				// enum of one type would reject the values of the other types.
+				if key != "enum" {
+					schema[key] = value
+				}
+			}
+		}
+		schema["type"] = typeNames
+		return schema
+	}
+
+	variants := make([]interface{}, len(types))
+	for i, t := range types {
+		variants[i] = n.schemaOf(t, strictness)
+	}
+	return map[string]interface{}{"oneOf": variants}
+}
+
+func (n *SchemaNode) schemaOf(t string, strictness SchemaStrictness) map[string]interface{} {
+	schema := map[string]interface{}{"type": t}
+
+	switch t {
+	case "array":
+		if n.items != nil {
+			schema["items"] = n.items.schema(strictness)
+		}
+		if n.isRoot {
+			break
+		}
+		if strictness >= standardSchemaStrictness {
+			schema["minItems"] = n.minItems
+			schema["maxItems"] = n.maxItems
+		}
+		if strictness >= strictSchemaStrictness && n.uniqueItems && n.maxItems > 1 {
+			schema["uniqueItems"] = true
+		}
+	case "object":
+		properties := make(map[string]interface{})
+		var required []interface{}
+		var keys []string
+		for key := range n.properties {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			properties[key] = n.properties[key].schema(strictness)
+			if n.propertyCounts[key] == n.objectCount {
+				required = append(required, key)
+			}
+		}
+		schema["properties"] = properties
+		schema["additionalProperties"] = false
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+	case "number":
+		if strictness >= standardSchemaStrictness {
+			schema["minimum"] = n.minimum
+			schema["maximum"] = n.maximum
+		}
+	case "string":
+		if strictness >= standardSchemaStrictness {
+			schema["maxLength"] = n.maxLength
+		}
+		if strictness >= strictSchemaStrictness {
+			if !n.hasManyStrings && len(n.stringValues) < n.stringCount {
+				var values []string
+				for value := range n.stringValues {
+					values = append(values, value)
+				}
+				sort.Strings(values)
+				enum := make([]interface{}, len(values))
+				for i, value := range values {
+					enum[i] = value
+				}
+				schema["enum"] = enum
+			} else if !n.hasEmptyString {
+				for i, matches := range n.patternMatches {
+					if matches {
+						schema["pattern"] = stringPatternHints[i]
+						break
+					}
+				}
+			}
+		}
+	}
+	return schema
+}
+
+// applyColumnSchemas sets the keywords of typed columns into the schema inferred from master data.
+// The column names are dotted paths from each row, e.g. "items.0.start_at".
+func applyColumnSchemas(schema map[string]interface{}, columnSchemas map[string]map[string]interface{}) {
+	for columnName, keywords := range columnSchemas {
+		node, _ := schema["items"].(map[string]interface{})
+		for _, key := range strings.Split(columnName, ".") {
+			if node == nil {
+				break
+			}
+			if isArrayIndex(key) || key == multiRowArrayKey {
+				node, _ = node["items"].(map[string]interface{})
+			} else {
+				properties, _ := node["properties"].(map[string]interface{})
+				node, _ = properties[key].(map[string]interface{})
+			}
+		}
+		if node == nil {
+			continue
+		}
+
+		isNullable := hasSchemaType(node, "null")
+		for keyword, value := range keywords {
+			// Empty cells are null, so null is kept in the type and enum.
+			if keyword == "type" && isNullable {
+				value = []interface{}{value, "null"}
+			} else if values, ok := value.([]interface{}); ok && keyword == "enum" && isNullable {
+				value = append(append([]interface{}{}, values...), nil)
+			}
+			node[keyword] = value
+		}
+	}
+}
+
+func hasSchemaType(schema map[string]interface{}, name string) bool {
+	switch t := schema["type"].(type) {
+	case string:
+		return t == name
+	case []interface{}:
+		for _, typeName := range t {
+			if typeName == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// mergeJSONSchema adds the properties of the inferred schema into the existing schema,
+// and returns the summary of the changes. User-authored keywords of the existing schema are kept.
+func mergeJSONSchema(existing map[string]interface{}, inferred map[string]interface{}, path string) []string {
+	var changes []string
+
+	existingType, inferredType := fmt.Sprint(existing["type"]), fmt.Sprint(inferred["type"])
+	if existing["type"] != nil && inferred["type"] != nil && existingType != inferredType &&
+		!(existingType == "integer" && inferredType == "number") {
+		changes = append(changes, fmt.Sprintf("Type differs: %v (schema: %v, inferred: %v)",
+			schemaPathName(path), existingType, inferredType))
+	}
+
+	if existingItems, ok := existing["items"].(map[string]interface{}); ok {
+		if inferredItems, ok := inferred["items"].(map[string]interface{}); ok {
+			changes = append(changes, mergeJSONSchema(existingItems, inferredItems, joinSchemaPath(path, "*"))...)
+		}
+	}
+
+	inferredProperties, _ := inferred["properties"].(map[string]interface{})
+	existingProperties, ok := existing["properties"].(map[string]interface{})
+	if !ok {
+		// The inferred properties are added to an object which has no properties.
+		if inferredProperties == nil || (existing["type"] != nil && existingType != "object") {
+			return changes
+		}
+		existingProperties = make(map[string]interface{})
+		existing["properties"] = existingProperties
+	}
+	inferredRequired := requiredKeys(inferred)
+
+	var keys []string
+	for key := range inferredProperties {
+		keys = append(keys, key)
+	}
+	for key := range existingProperties {
+		if _, ok := inferredProperties[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		propertyPath := joinSchemaPath(path, key)
+		existingProperty, isExisting := existingProperties[key].(map[string]interface{})
+		inferredProperty, isInferred := inferredProperties[key].(map[string]interface{})
+
+		switch {
+		case isExisting && isInferred:
+			changes = append(changes, mergeJSONSchema(existingProperty, inferredProperty, propertyPath)...)
+		case isInferred:
+			existingProperties[key] = inferredProperty
+			if inferredRequired[key] {
+				required, _ := existing["required"].([]interface{})
+				existing["required"] = append(required, key)
+			}
+			changes = append(changes, "Added: "+schemaPathName(propertyPath))
+		case isExisting:
+			// The definition is kept for the hand-written keywords, but the regenerated records don't have it.
+			if required, ok := existing["required"].([]interface{}); ok {
+				if required = removeRequiredKey(required, key); len(required) == 0 {
+					delete(existing, "required")
+				} else {
+					existing["required"] = required
+				}
+			}
+			changes = append(changes, "Removed from CSV (kept in schema, not required): "+schemaPathName(propertyPath))
+		}
+	}
+	return changes
+}
+
+// removeRequiredKey returns the required keys without the key.
+func removeRequiredKey(required []interface{}, key string) []interface{} {
+	var result []interface{}
+	for _, requiredKey := range required {
+		if requiredKey != key {
+			result = append(result, requiredKey)
+		}
+	}
+	return result
+}
+
+func joinSchemaPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func schemaPathName(path string) string {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "*"), ".")
+	if path == "" {
+		return "(root)"
+	}
+	return path
+}
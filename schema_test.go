@@ -0,0 +1,171 @@
+package main
+
+import (
+	"github.com/jeffail/gabs"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestSchema(t *testing.T) {
+	Convey("schema", t, func() {
+		Convey(".parseSchemaStrictness", func() {
+			Convey("should return the schema strictness", func() {
+				actual, err := parseSchemaStrictness("strict")
+				So(err, ShouldBeNil)
+				So(actual, ShouldEqual, strictSchemaStrictness)
+			})
+
+			Convey("with unknown name", func() {
+				Convey("should return an error", func() {
+					_, err := parseSchemaStrictness("foo")
+					So(err, ShouldNotBeNil)
+				})
+			})
+		})
+
+		Convey(".getJSONSchema", func() {
+			Convey("should merge types of every row and every array item", func() {
+				jsonText := `[
+				{ "id": 1, "items": [] },
+				{ "id": 2, "items": [{ "name": "foo" }, { "name": "bar", "count": 1 }] }
+				]`
+				masterData, _ := newMasterData("foo.json", jsonText, 0)
+
+				actual := getJSONSchema(masterData.container.Data(), basicSchemaStrictness)
+				So(actual.String(), ShouldEqual, `{"items":{"additionalProperties":false,`+
+					`"properties":{"id":{"type":"number"},"items":{"items":{"additionalProperties":false,`+
+					`"properties":{"count":{"type":"number"},"name":{"type":"string"}},"required":["name"],"type":"object"},`+
+					`"type":"array"}},"required":["id","items"],"type":"object"},"type":"array"}`)
+				So(validateJSONWithSchemaText(jsonText, actual.String()), ShouldBeNil)
+			})
+
+			Convey("with different primitive types", func() {
+				Convey("should return union types", func() {
+					actual := getJSONSchema([]interface{}{1.0, "foo", true}, basicSchemaStrictness)
+					So(actual.String(), ShouldEqual, `{"items":{"type":["boolean","number","string"]},"type":"array"}`)
+				})
+			})
+
+			Convey("with structured and primitive types", func() {
+				Convey("should return oneOf schemas", func() {
+					actual := getJSONSchema([]interface{}{"foo", []interface{}{1.0}}, basicSchemaStrictness)
+					So(actual.String(), ShouldEqual,
+						`{"items":{"oneOf":[{"items":{"type":"number"},"type":"array"},{"type":"string"}]},"type":"array"}`)
+				})
+			})
+
+			Convey("with standard strictness", func() {
+				Convey("should return ranges of values", func() {
+					actual := getJSONSchema([]interface{}{
+						map[string]interface{}{"count": 3.0, "name": "foo", "items": []interface{}{1.0}},
+						map[string]interface{}{"count": 1.0, "name": "ムーミン!", "items": []interface{}{2.0, 3.0}},
+					}, standardSchemaStrictness)
+					So(actual.Path("items.properties.count").String(), ShouldEqual, `{"maximum":3,"minimum":1,"type":"number"}`)
+					So(actual.Path("items.properties.name").String(), ShouldEqual, `{"maxLength":5,"type":"string"}`)
+					So(actual.Path("items.properties.items").String(), ShouldEqual,
+						`{"items":{"maximum":3,"minimum":1,"type":"number"},"maxItems":2,"minItems":1,"type":"array"}`)
+				})
+
+				Convey("should not constrain the number of the records", func() {
+					actual := getJSONSchema([]interface{}{
+						map[string]interface{}{"id": 1.0},
+						map[string]interface{}{"id": 2.0},
+					}, strictSchemaStrictness)
+					So(actual.Exists("minItems"), ShouldBeFalse)
+					So(actual.Exists("maxItems"), ShouldBeFalse)
+					So(actual.Exists("uniqueItems"), ShouldBeFalse)
+				})
+			})
+
+			Convey("with strict strictness", func() {
+				Convey("should return enums, patterns and uniqueItems", func() {
+					actual := getJSONSchema([]interface{}{
+						map[string]interface{}{"gender": "male", "code": "foo_bar", "tags": []interface{}{"a", "b"}},
+						map[string]interface{}{"gender": "female", "code": "baz", "tags": []interface{}{"c"}},
+						map[string]interface{}{"gender": "male", "code": "qux", "tags": []interface{}{}},
+					}, strictSchemaStrictness)
+					So(actual.Path("items.properties.gender.enum").String(), ShouldEqual, `["female","male"]`)
+					So(actual.Path("items.properties.code.pattern").Data(), ShouldEqual, "^[a-z][a-z0-9_]*$")
+					So(actual.Path("items.properties.tags.uniqueItems").Data(), ShouldEqual, true)
+				})
+			})
+
+			Convey("with empty array", func() {
+				Convey("should return array type without items", func() {
+					actual := getJSONSchema([]interface{}{}, basicSchemaStrictness)
+					So(actual.String(), ShouldEqual, `{"type":"array"}`)
+				})
+			})
+		})
+
+		Convey(".mergeJSONSchema", func() {
+			Convey("should add new properties and keep user-authored keywords", func() {
+				existing, _ := gabs.ParseJSON([]byte(`{
+				"type": "array",
+				"items": {
+					"type": "object",
+					"properties": {
+						"id": { "type": "integer", "description": "ID" },
+						"gender": { "type": "string", "enum": ["female", "male"] },
+						"old": { "type": "string" }
+					},
+					"required": ["id"]
+				}
+				}`))
+				inferred := getJSONSchema([]interface{}{
+					map[string]interface{}{"id": 1.0, "gender": "male", "user": map[string]interface{}{"name": "foo"}},
+				}, basicSchemaStrictness)
+
+				changes := mergeJSONSchema(existing.Data().(map[string]interface{}),
+					inferred.Data().(map[string]interface{}), "")
+				So(changes, ShouldResemble, []string{
+					"Removed from CSV (kept in schema, not required): old",
+					"Added: user",
+				})
+				So(existing.Path("items.properties.id").String(), ShouldEqual, `{"description":"ID","type":"integer"}`)
+				So(existing.Path("items.properties.gender.enum").String(), ShouldEqual, `["female","male"]`)
+				So(existing.Path("items.properties.old").String(), ShouldEqual, `{"type":"string"}`)
+				So(existing.Path("items.properties.user.properties.name").String(), ShouldEqual, `{"type":"string"}`)
+				So(existing.Path("items.required").String(), ShouldEqual, `["id","user"]`)
+			})
+
+			Convey("should not require removed properties", func() {
+				existing, _ := gabs.ParseJSON([]byte(`{
+				"type": "object",
+				"properties": {
+					"id": { "type": "integer" },
+					"old": { "type": "string", "description": "Old" }
+				},
+				"required": ["id", "old"]
+				}`))
+				inferred := getJSONSchema(map[string]interface{}{"id": 1.0}, basicSchemaStrictness)
+				mergeJSONSchema(existing.Data().(map[string]interface{}), inferred.Data().(map[string]interface{}), "")
+				So(existing.Path("properties.old").String(), ShouldEqual, `{"description":"Old","type":"string"}`)
+				So(existing.Path("required").String(), ShouldEqual, `["id"]`)
+			})
+
+			Convey("with an object which has no properties", func() {
+				Convey("should add the inferred properties", func() {
+					existing := map[string]interface{}{"type": "object", "description": "User"}
+					inferred := getJSONSchema(map[string]interface{}{"id": 1.0}, basicSchemaStrictness)
+					changes := mergeJSONSchema(existing, inferred.Data().(map[string]interface{}), "")
+					So(changes, ShouldResemble, []string{"Added: id"})
+					actual, _ := gabs.Consume(existing)
+					So(actual.String(), ShouldEqual,
+						`{"description":"User","properties":{"id":{"type":"number"}},"required":["id"],"type":"object"}`)
+				})
+			})
+
+			Convey("with different types", func() {
+				Convey("should report the difference", func() {
+					existing := map[string]interface{}{"type": "object", "properties": map[string]interface{}{
+						"count": map[string]interface{}{"type": "string"},
+					}}
+					inferred := getJSONSchema(map[string]interface{}{"count": 1.0}, basicSchemaStrictness)
+					changes := mergeJSONSchema(existing, inferred.Data().(map[string]interface{}), "")
+					So(changes, ShouldResemble, []string{"Type differs: count (schema: string, inferred: number)"})
+				})
+			})
+		})
+	})
+}
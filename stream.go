@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+// streamCSV converts the CSV data to the records, and writes them one by one,
+// so that the memory is bounded by a record even if the CSV data is huge.
+// The data is read twice by open: the types of the columns are detected in the first pass,
+// which is skipped if all types are declared in the header, and the records are converted in the second pass.
+func streamCSV(path string, open func() (io.ReadCloser, error), options *CSVTableOptions, write func(interface{}) error) error {
+	if options.sortBy != "" {
+		return fmt.Errorf("Records cannot be sorted in streaming")
+	}
+
+	var columns []*CSVColumn
+	var indexes []int
+	err := readCSVRecords(open, func(record []string, line int) error {
+		if columns == nil {
+			var err error
+			if indexes, err = csvColumnIndexesWithoutIgnored(record, options.ignoreColumns); err != nil {
+				return err
+			}
+			if columns, err = newCSVHeaderColumns(selectCSVColumns(record, indexes), options); err != nil {
+				return err
+			}
+			if hasDeclaredCSVColumnTypes(columns) {
+				return errStopReadingCSV
+			}
+			return nil
+		}
+		if isDisabledCSVRecord(record) {
+			return nil
+		}
+		return detectCSVColumnTypes(columns, selectCSVColumns(record, indexes), options)
+	})
+	if err != nil {
+		return err
+	} else if columns == nil {
+		return fmt.Errorf("CSV data should have 2 rows at a minimum: %v", path)
+	}
+
+	csvTable := &CSVTable{fileName: filepath.Base(path), columns: columns, options: options}
+	builder := newCSVRecordBuilder(csvTable)
+	keyIndex := csvColumnIndex(columns, options.keyColumn)
+	recordCount := 0
+	err = readCSVRecords(open, func(record []string, line int) error {
+		recordCount++
+		if line == 1 || isDisabledCSVRecord(record) {
+			return nil
+		}
+		record = selectCSVColumns(record, indexes)
+		row, err := convertCSVRecord(columns, record, csvLine{number: line}, options)
+		if err != nil {
+			return err
+		}
+		completed, err := builder.add(row, record[keyIndex], csvLine{number: line})
+		if err != nil || completed == nil {
+			return err
+		}
+		return write(completed)
+	})
+	if err != nil {
+		return err
+	} else if recordCount < 2 {
+		return fmt.Errorf("CSV data should have 2 rows at a minimum: %v", path)
+	}
+	if record := builder.flush(); record != nil {
+		return write(record)
+	}
+	return nil
+}
+
+// hasDeclaredCSVColumnTypes reports whether the kinds of all columns are declared in the header, e.g. "start_at:datetime".
+// The list columns need the first pass because the types of their items are detected.
+func hasDeclaredCSVColumnTypes(columns []*CSVColumn) bool {
+	for _, column := range columns {
+		if column.kind == "" || column.kind == listColumnKind {
+			return false
+		}
+	}
+	return true
+}
+
+// errStopReadingCSV is returned by the callback of readCSVRecords to stop reading the rest of the records.
+var errStopReadingCSV = errors.New("stop reading CSV data")
+
+// readCSVRecords calls the callback with each record and its line number, which is 1 for the header.
+func readCSVRecords(open func() (io.ReadCloser, error), callback func([]string, int) error) error {
+	reader, err := open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	csvReader := csv.NewReader(reader)
+	csvReader.ReuseRecord = true
+	for line := 1; ; line++ {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := callback(record, line); err == errStopReadingCSV {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// RecordWriter writes the records one by one in an output format.
+type RecordWriter interface {
+	write(record interface{}) error
+	close() error
+}
+
+// newRecordWriter returns the writer of the output format, which is json or jsonl.
+func newRecordWriter(writer io.Writer, format string, indent string) RecordWriter {
+	if format == "jsonl" {
+		return newJSONLinesWriter(writer)
+	}
+	return newJSONArrayWriter(writer, indent)
+}
+
+// JSONArrayWriter writes the records as a JSON array one by one, which is formatted like MasterData.json.
+type JSONArrayWriter struct {
+	writer *bufio.Writer
+	indent string
+	count  int
+}
+
+func newJSONArrayWriter(writer io.Writer, indent string) *JSONArrayWriter {
+	return &JSONArrayWriter{writer: bufio.NewWriter(writer), indent: indent}
+}
+
+func (w *JSONArrayWriter) write(record interface{}) error {
+	var data []byte
+	var err error
+	if w.indent == "" {
+		data, err = json.Marshal(record)
+	} else {
+		data, err = json.MarshalIndent(record, w.indent, w.indent)
+	}
+	if err != nil {
+		return err
+	}
+
+	separator := ","
+	if w.count == 0 {
+		separator = "["
+	}
+	if w.indent != "" {
+		separator += "\n" + w.indent
+	}
+	w.count++
+	if _, err := w.writer.WriteString(separator); err != nil {
+		return err
+	}
+	_, err = w.writer.Write(data)
+	return err
+}
+
+// close writes the end of the array, and flushes the written records.
+func (w *JSONArrayWriter) close() error {
+	end := "]"
+	if w.count == 0 {
+		end = "[]"
+	} else if w.indent != "" {
+		end = "\n]"
+	}
+	if _, err := w.writer.WriteString(end); err != nil {
+		return err
+	}
+	return w.writer.Flush()
+}
+
+// JSONLinesWriter writes the records as compact JSON, one record per line.
+type JSONLinesWriter struct {
+	writer *bufio.Writer
+}
+
+func newJSONLinesWriter(writer io.Writer) *JSONLinesWriter {
+	return &JSONLinesWriter{writer: bufio.NewWriter(writer)}
+}
+
+func (w *JSONLinesWriter) write(record interface{}) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err := w.writer.Write(data); err != nil {
+		return err
+	}
+	return w.writer.WriteByte('\n')
+}
+
+func (w *JSONLinesWriter) close() error {
+	return w.writer.Flush()
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jeffail/gabs"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStream(t *testing.T) {
+	Convey("stream", t, func() {
+		open := func(text string) func() (io.ReadCloser, error) {
+			return func() (io.ReadCloser, error) {
+				return ioutil.NopCloser(strings.NewReader(text)), nil
+			}
+		}
+		streamJSON := func(text string, options *CSVTableOptions, indent string) (string, error) {
+			var b bytes.Buffer
+			writer := newJSONArrayWriter(&b, indent)
+			if err := streamCSV("test.csv", open(text), options, writer.write); err != nil {
+				return "", err
+			}
+			err := writer.close()
+			return b.String(), err
+		}
+		convertJSON := func(text string, options *CSVTableOptions, indent string) string {
+			csvTable, err := newCSVTableWithOptions("test.csv", "utf-8", []byte(text), options)
+			So(err, ShouldBeNil)
+			data, err := csvTable.data()
+			So(err, ShouldBeNil)
+			container, err := gabs.Consume(data)
+			So(err, ShouldBeNil)
+			if indent == "" {
+				return container.String()
+			}
+			return container.StringIndent("", indent)
+		}
+
+		Convey(".streamCSV", func() {
+			Convey("should write the same JSON as the converted CSV table", func() {
+				texts := []string{
+					"id,name,price,tags:list,memo\n1,Sword,100,\"a,b\",x\n# 2,Dagger,,,\n3,Shield,1.5,c,\n4,Bow,TRUE,,\n",
+					"id,steps.*.text,steps.*.wait\n1,Hello,1\n,World,2\n2,Bye,\n",
+					"id,items.0.name,items.1.name,start_at:datetime\n1,a,b,2020-01-01 00:00:00\n2,,,\n",
+				}
+				options := &CSVTableOptions{location: time.UTC, ignoreColumns: []string{"memo"}}
+				for _, text := range texts {
+					for _, indent := range []string{"", "  "} {
+						actual, err := streamJSON(text, options, indent)
+						So(err, ShouldBeNil)
+						So(actual, ShouldEqual, convertJSON(text, options, indent))
+					}
+				}
+			})
+
+			Convey("should write JSON Lines with jsonl format", func() {
+				var b bytes.Buffer
+				writer := newRecordWriter(&b, "jsonl", "  ")
+				err := streamCSV("test.csv", open("id,name\n1,Sword\n2,Shield\n"), &CSVTableOptions{}, writer.write)
+				So(err, ShouldBeNil)
+				So(writer.close(), ShouldBeNil)
+				So(b.String(), ShouldEqual, "{\"id\":1,\"name\":\"Sword\"}\n{\"id\":2,\"name\":\"Shield\"}\n")
+			})
+
+			Convey("should write an empty array if all rows are disabled", func() {
+				actual, err := streamJSON("id,name\n# 1,Sword\n", &CSVTableOptions{}, "  ")
+				So(err, ShouldBeNil)
+				So(actual, ShouldEqual, "[]")
+			})
+
+			Convey("should detect the types in the first pass", func() {
+				actual, err := streamJSON("id,price\n1,100\n2,1.5\n", &CSVTableOptions{}, "")
+				So(err, ShouldBeNil)
+				So(actual, ShouldEqual, `[{"id":1,"price":100},{"id":2,"price":1.5}]`)
+			})
+
+			Convey("should skip the first pass after the header if all kinds are declared", func() {
+				texts := []string{"start_at:datetime\n\"broken", "start_at:datetime\n2020-01-01 00:00:00\n"}
+				opener := func() (io.ReadCloser, error) {
+					text := texts[0]
+					texts = texts[1:]
+					return open(text)()
+				}
+				var records []interface{}
+				err := streamCSV("test.csv", opener, &CSVTableOptions{location: time.UTC}, func(record interface{}) error {
+					records = append(records, record)
+					return nil
+				})
+				So(err, ShouldBeNil)
+				So(records, ShouldHaveLength, 1)
+			})
+
+			Convey("with the header only", func() {
+				Convey("should return error", func() {
+					_, err := streamJSON("id,name\n", &CSVTableOptions{}, "")
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldEqual, "CSV data should have 2 rows at a minimum: test.csv")
+				})
+			})
+
+			Convey("with sortBy option", func() {
+				Convey("should return error", func() {
+					_, err := streamJSON("id,name\n1,Sword\n", &CSVTableOptions{sortBy: "name"}, "")
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldEqual, "Records cannot be sorted in streaming")
+				})
+			})
+
+			Convey("with an invalid value", func() {
+				Convey("should return error with the line number", func() {
+					_, err := streamJSON("id,start_at:datetime\n1,2020-01-01 00:00:00\n2,invalid\n", &CSVTableOptions{location: time.UTC}, "")
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldContainSubstring, "line 3")
+				})
+			})
+		})
+	})
+}
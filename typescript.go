@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	typeScriptIdentifierPattern = regexp.MustCompile("^[A-Za-z_$][A-Za-z0-9_$]*$")
+	typeNameSeparatorPattern    = regexp.MustCompile("[^A-Za-z0-9]+")
+)
+
+// typeScriptGenerator generates the declarations of the types. The interface names are derived from the paths
+// of the objects, so the paths are kept by the names to report the paths whose names conflict, e.g. "user_name"
+// and "user.name".
+type typeScriptGenerator struct {
+	readonly       bool
+	enums          map[string]*Enum
+	declaredEnums  map[string]bool
+	declarations   []string
+	interfacePaths map[string]string
+	err            error
+}
+
+func (m *MasterData) typeScript(readonly bool) (string, error) {
+	name := typeName(m.tableName())
+	generator := &typeScriptGenerator{
+		readonly:       readonly,
+		enums:          m.enums,
+		declaredEnums:  make(map[string]bool),
+		interfacePaths: make(map[string]string),
+	}
+	schema, _ := m.schema(basicSchemaStrictness).Data().(map[string]interface{})
+
+	if items, ok := schema["items"].(map[string]interface{}); ok && schema["type"] == "array" {
+		itemType := generator.typeOf(name, "", items)
+		generator.declare(fmt.Sprintf("export type %vList = %v;\n", name, generator.arrayOf(itemType)))
+	} else if t := generator.typeOf(name, "", schema); t != name {
+		generator.declare(fmt.Sprintf("export type %v = %v;\n", name, t))
+	}
+	if generator.err != nil {
+		return "", generator.err
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Generated by master from %v. DO NOT EDIT.\n", m.fileName)
+	for _, declaration := range generator.declarations {
+		b.WriteString("\n")
+		b.WriteString(declaration)
+	}
+	return b.String(), nil
+}
+
+func (g *typeScriptGenerator) declare(declaration string) int {
+	g.declarations = append(g.declarations, declaration)
+	return len(g.declarations) - 1
+}
+
+// typeOf returns the type of the schema, whose path is the column name of the value, e.g. "user.name".
+func (g *typeScriptGenerator) typeOf(name string, path string, schema map[string]interface{}) string {
+	if title, ok := schema["title"].(string); ok && g.enums[title] != nil {
+		enumType := g.enumOf(g.enums[title])
+		if hasSchemaType(schema, "null") {
+			return enumType + " | null"
+		}
+		return enumType
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		literals := make([]string, len(enum))
+		for i, value := range enum {
+			literal, _ := json.Marshal(value)
+			literals[i] = string(literal)
+		}
+		return strings.Join(literals, " | ")
+	}
+	if variants, ok := schema["oneOf"].([]interface{}); ok {
+		types := make([]string, len(variants))
+		for i, variant := range variants {
+			variantSchema, _ := variant.(map[string]interface{})
+			types[i] = g.typeOf(name+strconv.Itoa(i+1), path, variantSchema)
+		}
+		return strings.Join(types, " | ")
+	}
+	if typeNames, ok := schema["type"].([]interface{}); ok {
+		types := make([]string, len(typeNames))
+		for i, typeName := range typeNames {
+			variantSchema := make(map[string]interface{})
+			for key, value := range schema {
+				variantSchema[key] = value
+			}
+			variantSchema["type"] = typeName
+			types[i] = g.typeOf(name, path, variantSchema)
+		}
+		return strings.Join(types, " | ")
+	}
+
+	switch schema["type"] {
+	case "object":
+		properties, _ := schema["properties"].(map[string]interface{})
+		if len(properties) == 0 {
+			return "{ [key: string]: any }"
+		}
+		return g.interfaceOf(name, path, properties, requiredKeys(schema))
+	case "array":
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			return g.arrayOf(g.typeOf(name, joinSchemaPath(path, "*"), items))
+		}
+		return g.arrayOf("any")
+	case "string":
+		return "string"
+	case "number", "integer":
+		return "number"
+	case "boolean":
+		return "boolean"
+	case "null":
+		return "null"
+	}
+	return "any"
+}
+
+func (g *typeScriptGenerator) interfaceOf(name string, path string,
+	properties map[string]interface{}, required map[string]bool) string {
+	if otherPath, ok := g.interfacePaths[name]; ok && otherPath != path && g.err == nil {
+		g.err = fmt.Errorf("TypeScript interface names of %v and %v conflict: %v",
+			schemaPathName(otherPath), schemaPathName(path), name)
+	}
+	g.interfacePaths[name] = path
+	index := g.declare("")
+
+	var keys []string
+	for key := range properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "export interface %v {\n", name)
+	for _, key := range keys {
+		propertySchema, _ := properties[key].(map[string]interface{})
+		propertyType := g.typeOf(name+typeName(key), joinSchemaPath(path, key), propertySchema)
+
+		b.WriteString("  ")
+		if g.readonly {
+			b.WriteString("readonly ")
+		}
+		if typeScriptIdentifierPattern.MatchString(key) {
+			b.WriteString(key)
+		} else {
+			b.WriteString(strconv.Quote(key))
+		}
+		if !required[key] {
+			b.WriteString("?")
+		}
+		fmt.Fprintf(&b, ": %v;\n", propertyType)
+	}
+	b.WriteString("}\n")
+
+	g.declarations[index] = b.String()
+	return name
+}
+
+func (g *typeScriptGenerator) enumOf(enum *Enum) string {
+	if g.declaredEnums[enum.name] {
+		return enum.name
+	}
+	g.declaredEnums[enum.name] = true
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "export const enum %v {\n", enum.name)
+	for _, label := range enum.labels() {
+		if typeScriptIdentifierPattern.MatchString(label) {
+			fmt.Fprintf(&b, "  %v = %v,\n", label, enum.values[label])
+		} else {
+			fmt.Fprintf(&b, "  %v = %v,\n", strconv.Quote(label), enum.values[label])
+		}
+	}
+	b.WriteString("}\n")
+	g.declare(b.String())
+	return enum.name
+}
+
+func (g *typeScriptGenerator) arrayOf(itemType string) string {
+	if g.readonly {
+		return "ReadonlyArray<" + itemType + ">"
+	}
+	if strings.Contains(itemType, " ") {
+		return "(" + itemType + ")[]"
+	}
+	return itemType + "[]"
+}
+
+func requiredKeys(schema map[string]interface{}) map[string]bool {
+	result := make(map[string]bool)
+	switch required := schema["required"].(type) {
+	case []string:
+		for _, key := range required {
+			result[key] = true
+		}
+	case []interface{}:
+		for _, key := range required {
+			if keyAsString, ok := key.(string); ok {
+				result[keyAsString] = true
+			}
+		}
+	}
+	return result
+}
+
+func typeName(value string) string {
+	var b bytes.Buffer
+	for _, word := range typeNameSeparatorPattern.Split(value, -1) {
+		if word != "" {
+			b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	name := b.String()
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "T" + name
+	}
+	return name
+}
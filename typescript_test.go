@@ -0,0 +1,94 @@
+package main
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestTypeScript(t *testing.T) {
+	Convey("typescript", t, func() {
+		jsonText := `[
+		{ "id": 1, "user": { "name": "foo" }, "tags": ["a", "b"], "is_active": true }
+		]`
+
+		Convey("MasterData#typeScript", func() {
+			Convey("should return TypeScript interface declarations", func() {
+				masterData, _ := newMasterData("user_items.json", jsonText, 0)
+				actual, err := masterData.typeScript(false)
+				So(err, ShouldBeNil)
+				So(actual, ShouldEqual, `// Generated by master from user_items.json. DO NOT EDIT.
+
+export interface UserItems {
+  id: number;
+  is_active: boolean;
+  tags: string[];
+  user: UserItemsUser;
+}
+
+export interface UserItemsUser {
+  name: string;
+}
+
+export type UserItemsList = UserItems[];
+`)
+			})
+
+			Convey("with enum columns", func() {
+				Convey("should return enum declarations", func() {
+					options := &CSVTableOptions{enums: map[string]*Enum{
+						"Rarity": &Enum{name: "Rarity", values: map[string]int{"R": 1, "SR": 2}},
+					}}
+					csvTable, _ := newCSVTableWithOptions("cards.csv", "utf-8",
+						[]byte("id,rarity:Rarity,sub_rarity:Rarity\n1,R,\n2,SR,R"), options)
+					masterData, _ := newMasterDataFromCSV(csvTable, 0)
+					actual, err := masterData.typeScript(false)
+					So(err, ShouldBeNil)
+					So(actual, ShouldEqual, `// Generated by master from cards.json. DO NOT EDIT.
+
+export interface Cards {
+  id: number;
+  rarity: Rarity;
+  sub_rarity: Rarity | null;
+}
+
+export const enum Rarity {
+  R = 1,
+  SR = 2,
+}
+
+export type CardsList = Cards[];
+`)
+				})
+			})
+
+			Convey("with readonly option", func() {
+				Convey("should return readonly declarations", func() {
+					masterData, _ := newMasterData("user_items.json", jsonText, 0)
+					actual, _ := masterData.typeScript(true)
+					So(actual, ShouldContainSubstring, "  readonly tags: ReadonlyArray<string>;\n")
+					So(actual, ShouldContainSubstring, "  readonly name: string;\n")
+					So(actual, ShouldContainSubstring, "export type UserItemsList = ReadonlyArray<UserItems>;\n")
+				})
+			})
+
+			Convey("with objects whose interface names conflict", func() {
+				Convey("should return an error", func() {
+					masterData, _ := newMasterData("user_items.json",
+						`[{ "user_name": { "first": "foo" }, "user": { "name": { "last": "bar" } } }]`, 0)
+					_, err := masterData.typeScript(false)
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldEqual,
+						"TypeScript interface names of user.name and user_name conflict: UserItemsUserName")
+				})
+			})
+		})
+
+		Convey(".typeName", func() {
+			Convey("should return a PascalCase type name", func() {
+				So(typeName("masterdata-utf-8"), ShouldEqual, "MasterdataUtf8")
+				So(typeName("voice_actors"), ShouldEqual, "VoiceActors")
+				So(typeName("0"), ShouldEqual, "T0")
+			})
+		})
+	})
+}
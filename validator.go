@@ -1,26 +1,217 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/xeipuuv/gojsonschema"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
 )
 
-func validateJSON(jsonText string, schemaText string) error {
-	schemaLoader := gojsonschema.NewStringLoader(schemaText)
-	docLoader := gojsonschema.NewStringLoader(jsonText)
+var colorFormatPattern = regexp.MustCompile("^#[0-9A-Fa-f]{6}([0-9A-Fa-f]{2})?$")
+
+// formatHints describe the expected values of the format keyword in validation error messages.
+var formatHints = map[string]string{
+	"date-time":  "RFC 3339 date-time, e.g. 2006-01-02T15:04:05+09:00",
+	"date":       "RFC 3339 full-date, e.g. 2006-01-02",
+	"uri":        "absolute URI, e.g. https://example.com/",
+	"email":      "email address, e.g. alice@example.com",
+	"uuid":       "UUID, e.g. 123e4567-e89b-12d3-a456-426614174000",
+	"color":      "hex color, e.g. #FF8800 or #FF880080",
+	"asset-path": "slash-separated relative path to an existing file in the asset directory",
+}
 
-	result, err := gojsonschema.Validate(schemaLoader, docLoader)
+// validateJSONWithSchemaFile validates the JSON text by the JSON Schema file.
+// Relative $ref in the schema is resolved within the schema directory,
+// and the asset-path format is checked against the asset directory if it's given.
+func validateJSONWithSchemaFile(jsonText string, schemaPath string, schemaDir string, assetDir string) error {
+	schema, err := newSchemaCompiler(schemaDir, assetDir).Compile(schemaPath)
 	if err != nil {
 		return err
 	}
+	return validateJSONBySchema(jsonText, schema)
+}
+
+func newSchemaCompiler(schemaDir string, assetDir string) *jsonschema.Compiler {
+	compiler := jsonschema.NewCompiler()
+	// The draft is selected by $schema, and draft-04 is used if it's missing.
+	compiler.Draft = jsonschema.Draft4
+	// The format keyword is only an annotation by default since draft 2019-09.
+	compiler.AssertFormat = true
+	compiler.Formats["color"] = isColor
+	compiler.Formats["asset-path"] = assetPathFormat(assetDir)
+	compiler.LoadURL = func(s string) (io.ReadCloser, error) {
+		u, err := url.Parse(s)
+		if err != nil {
+			return nil, err
+		}
+		if u.Scheme != "file" {
+			return nil, fmt.Errorf("Only schema files can be referenced: %v", s)
+		}
+		path := filepath.FromSlash(u.Path)
+		if !isInDirectory(path, schemaDir) {
+			return nil, fmt.Errorf("Referenced schema file is not in the schema directory: %v", path)
+		}
+		return os.Open(path)
+	}
+	return compiler
+}
+
+// validateJSONLinesWithSchemaFile validates each line of the JSON Lines text by the items subschema of the JSON Schema file.
+func validateJSONLinesWithSchemaFile(jsonLines string, schemaPath string, schemaDir string, assetDir string) error {
+	schema, err := newSchemaCompiler(schemaDir, assetDir).Compile(schemaPath)
+	if err != nil {
+		return err
+	}
+	itemsSchema, err := itemsSubschema(schema)
+	if err != nil || itemsSchema == nil {
+		return err
+	}
 
-	if !result.Valid() {
-		errMessage := "The JSON data is not valid:\n"
-		for _, desc := range result.Errors() {
-			errMessage += fmt.Sprintf("  %v\n", desc)
+	var messages []string
+	for i, line := range strings.Split(jsonLines, "\n") {
+		if line == "" {
+			continue
+		}
+		doc, err := decodeJSON(line)
+		if err != nil {
+			return err
 		}
-		return errors.New(errMessage)
+		err = itemsSchema.Validate(doc)
+		if validationErr, ok := err.(*jsonschema.ValidationError); ok {
+			for _, message := range validationErrorMessages(validationErr) {
+				messages = append(messages, fmt.Sprintf("line %v: %v", i+1, message))
+			}
+		} else if err != nil {
+			return err
+		}
+	}
+	if len(messages) > 0 {
+		return validationError(messages)
 	}
 	return nil
 }
+
+// itemsSubschema returns the subschema of the array items, or nil if the items are not constrained at all.
+// It returns error if the items are constrained by other keywords, e.g. items in allOf or oneOf,
+// or the whole array is constrained, e.g. uniqueItems, because the records would pass without validation.
+func itemsSubschema(schema *jsonschema.Schema) (*jsonschema.Schema, error) {
+	for schema.Ref != nil && schema.Items == nil && schema.Items2020 == nil {
+		schema = schema.Ref
+	}
+	if _, ok := schema.Items.([]*jsonschema.Schema); ok || len(schema.PrefixItems) > 0 {
+		return nil, errors.New("JSON Schema should have a single items subschema to validate each record")
+	}
+	if schema.Ref != nil || schema.RecursiveRef != nil || schema.DynamicRef != nil || schema.Not != nil ||
+		len(schema.AllOf) > 0 || len(schema.AnyOf) > 0 || len(schema.OneOf) > 0 || schema.If != nil ||
+		schema.Contains != nil || schema.UnevaluatedItems != nil {
+		return nil, errors.New("JSON Schema should constrain the records only by the items subschema to validate each record")
+	}
+	if schema.MinItems != -1 || schema.MaxItems != -1 || schema.UniqueItems {
+		return nil, errors.New("JSON Schema should not constrain the whole array, e.g. uniqueItems, to validate each record")
+	}
+	if items, ok := schema.Items.(*jsonschema.Schema); ok {
+		return items, nil
+	}
+	return schema.Items2020, nil
+}
+
+func validateJSONBySchema(jsonText string, schema *jsonschema.Schema) error {
+	doc, err := decodeJSON(jsonText)
+	if err != nil {
+		return err
+	}
+
+	err = schema.Validate(doc)
+	if validationErr, ok := err.(*jsonschema.ValidationError); ok {
+		return validationError(validationErrorMessages(validationErr))
+	}
+	return err
+}
+
+// decodeJSON decodes the JSON text keeping the numbers as json.Number, which JSON Schema validates precisely.
+func decodeJSON(jsonText string) (interface{}, error) {
+	decoder := json.NewDecoder(strings.NewReader(jsonText))
+	decoder.UseNumber()
+	var doc interface{}
+	err := decoder.Decode(&doc)
+	return doc, err
+}
+
+func validationError(messages []string) error {
+	errMessage := "The JSON data is not valid:\n"
+	for _, message := range messages {
+		errMessage += fmt.Sprintf("  %v\n", message)
+	}
+	return errors.New(errMessage)
+}
+
+func validationErrorMessages(err *jsonschema.ValidationError) []string {
+	if len(err.Causes) == 0 {
+		message := err.Message
+		if strings.HasSuffix(err.KeywordLocation, "/format") {
+			for format, hint := range formatHints {
+				if strings.HasSuffix(message, "'"+format+"'") {
+					message += fmt.Sprintf(" (expected %v)", hint)
+				}
+			}
+		}
+		return []string{fmt.Sprintf("%v: %v", instancePathName(err.InstanceLocation), message)}
+	}
+	var result []string
+	for _, cause := range err.Causes {
+		result = append(result, validationErrorMessages(cause)...)
+	}
+	return result
+}
+
+func instancePathName(location string) string {
+	if location == "" {
+		return "(root)"
+	}
+	return strings.Replace(strings.TrimPrefix(location, "/"), "/", ".", -1)
+}
+
+func isColor(value interface{}) bool {
+	s, ok := value.(string)
+	return !ok || colorFormatPattern.MatchString(s)
+}
+
+// assetPathFormat returns the format function which checks the asset path exists in the asset directory.
+// Only the path syntax is checked if the asset directory is not given.
+func assetPathFormat(assetDir string) func(interface{}) bool {
+	return func(value interface{}) bool {
+		s, ok := value.(string)
+		if !ok {
+			return true
+		}
+		if s == "" || strings.Contains(s, "\\") || path.IsAbs(s) || path.Clean(s) != s ||
+			s == "." || s == ".." || strings.HasPrefix(s, "../") {
+			return false
+		}
+		if assetDir == "" {
+			return true
+		}
+		stat, err := os.Stat(filepath.Join(assetDir, filepath.FromSlash(s)))
+		return err == nil && !stat.IsDir()
+	}
+}
+
+func isInDirectory(path string, dir string) bool {
+	if dir == "" {
+		return false
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
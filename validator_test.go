@@ -1,8 +1,14 @@
 package main
 
 import (
-	. "github.com/smartystreets/goconvey/convey"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	. "github.com/smartystreets/goconvey/convey"
 )
 
 func TestValidator(t *testing.T) {
@@ -22,20 +28,202 @@ func TestValidator(t *testing.T) {
 	}`
 
 	Convey("validator", t, func() {
-		Convey(".validate", func() {
+		Convey(".validateJSONWithSchemaFile with records", func() {
 			Convey("with valid JSON text", func() {
 				Convey("should return no error", func() {
-					err := validateJSON(`[{"id": 1}, {"id": 2}]`, schema)
+					err := validateJSONWithSchemaText(`[{"id": 1}, {"id": 2}]`, schema)
 					So(err, ShouldBeNil)
 				})
 			})
 
 			Convey("with invalid JSON text", func() {
 				Convey("should return error", func() {
-					err := validateJSON(`{"id": "foo"}`, schema)
+					err := validateJSONWithSchemaText(`{"id": "foo"}`, schema)
+					So(err, ShouldNotBeNil)
+				})
+			})
+		})
+
+		Convey(".validateJSONWithSchemaFile with newer drafts", func() {
+			Convey("should select the draft by $schema", func() {
+				schema2020 := `{
+					"$schema": "https://json-schema.org/draft/2020-12/schema",
+					"type": "array",
+					"prefixItems": [{ "type": "integer" }, { "type": "string" }]
+				}`
+				So(validateJSONWithSchemaText(`[1, "foo"]`, schema2020), ShouldBeNil)
+				So(validateJSONWithSchemaText(`["foo", 1]`, schema2020), ShouldNotBeNil)
+
+				schema07 := `{ "$schema": "http://json-schema.org/draft-07/schema#", "const": 1 }`
+				So(validateJSONWithSchemaText(`1`, schema07), ShouldBeNil)
+				So(validateJSONWithSchemaText(`2`, schema07), ShouldNotBeNil)
+			})
+		})
+
+		Convey(".validateJSONWithSchemaFile with format", func() {
+			formatSchema := func(format string) string {
+				return `{ "$schema": "https://json-schema.org/draft/2020-12/schema", "format": "` + format + `" }`
+			}
+
+			Convey("should validate the standard formats", func() {
+				So(validateJSONWithSchemaText(`"2017-01-01T12:00:00+09:00"`, formatSchema("date-time")), ShouldBeNil)
+				So(validateJSONWithSchemaText(`"2017-01-01"`, formatSchema("date")), ShouldBeNil)
+				So(validateJSONWithSchemaText(`"https://example.com/"`, formatSchema("uri")), ShouldBeNil)
+				So(validateJSONWithSchemaText(`"alice@example.com"`, formatSchema("email")), ShouldBeNil)
+				So(validateJSONWithSchemaText(`"123e4567-e89b-12d3-a456-426614174000"`, formatSchema("uuid")), ShouldBeNil)
+
+				err := validateJSONWithSchemaText(`"2017/01/01 12:00"`, formatSchema("date-time"))
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "(root): '2017/01/01 12:00' is not valid 'date-time' (expected RFC 3339 date-time")
+				So(validateJSONWithSchemaText(`"example.com"`, formatSchema("uri")), ShouldNotBeNil)
+			})
+
+			Convey("should validate the color format", func() {
+				So(validateJSONWithSchemaText(`"#FF8800"`, formatSchema("color")), ShouldBeNil)
+				So(validateJSONWithSchemaText(`"#ff880080"`, formatSchema("color")), ShouldBeNil)
+				So(validateJSONWithSchemaText(`"FF8800"`, formatSchema("color")), ShouldNotBeNil)
+				So(validateJSONWithSchemaText(`"#F80"`, formatSchema("color")), ShouldNotBeNil)
+			})
+
+			Convey("should validate the syntax of the asset-path format", func() {
+				So(validateJSONWithSchemaText(`"Assets/Icons/sword.png"`, formatSchema("asset-path")), ShouldBeNil)
+				So(validateJSONWithSchemaText(`"/Assets/Icons/sword.png"`, formatSchema("asset-path")), ShouldNotBeNil)
+				So(validateJSONWithSchemaText(`"../Icons/sword.png"`, formatSchema("asset-path")), ShouldNotBeNil)
+				So(validateJSONWithSchemaText(`"Assets\\Icons\\sword.png"`, formatSchema("asset-path")), ShouldNotBeNil)
+			})
+		})
+
+		Convey(".validateJSONWithSchemaFile", func() {
+			Convey("with asset directory", func() {
+				Convey("should check the asset-path files exist", func() {
+					err := validateJSONWithSchemaFile(`[{ "icon": "masterdata.csv" }]`,
+						"./fixtures/schemas/assets.schema.json", "./fixtures/schemas", "./fixtures")
+					So(err, ShouldBeNil)
+
+					err = validateJSONWithSchemaFile(`[{ "icon": "missing.png" }]`,
+						"./fixtures/schemas/assets.schema.json", "./fixtures/schemas", "./fixtures")
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldContainSubstring, "0.icon: 'missing.png' is not valid 'asset-path'")
+				})
+			})
+
+			Convey("with relative $ref", func() {
+				Convey("should resolve the referenced schema file", func() {
+					err := validateJSONWithSchemaFile(`[{ "id": 1, "reward": { "item_id": 2, "count": 3 } }]`,
+						"./fixtures/schemas/quests.schema.json", "./fixtures/schemas", "")
+					So(err, ShouldBeNil)
+				})
+
+				Convey("should return error with invalid JSON text", func() {
+					err := validateJSONWithSchemaFile(`[{ "id": 1, "reward": { "item_id": 2, "count": 0 } }]`,
+						"./fixtures/schemas/quests.schema.json", "./fixtures/schemas", "")
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldContainSubstring, "0.reward.count: ")
+				})
+			})
+
+			Convey("with $ref to outside of the schema directory", func() {
+				Convey("should return error", func() {
+					err := validateJSONWithSchemaFile(`[]`, "./fixtures/schemas/outside.schema.json", "./fixtures/schemas", "")
 					So(err, ShouldNotBeNil)
 				})
 			})
 		})
+
+		Convey(".validateJSONLinesWithSchemaFile", func() {
+			Convey("should validate each line by the items subschema", func() {
+				jsonLines := "{\"id\":1,\"reward\":{\"item_id\":2,\"count\":3}}\n{\"id\":2,\"reward\":{\"item_id\":2,\"count\":1}}\n"
+				err := validateJSONLinesWithSchemaFile(jsonLines, "./fixtures/schemas/quests.schema.json", "./fixtures/schemas", "")
+				So(err, ShouldBeNil)
+			})
+
+			Convey("should return error with the line numbers of the invalid records", func() {
+				jsonLines := "{\"id\":1,\"reward\":{\"item_id\":2,\"count\":3}}\n{\"id\":\"2\",\"reward\":{\"item_id\":2,\"count\":0}}\n"
+				err := validateJSONLinesWithSchemaFile(jsonLines, "./fixtures/schemas/quests.schema.json", "./fixtures/schemas", "")
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "line 2: id: ")
+				So(err.Error(), ShouldContainSubstring, "line 2: reward.count: ")
+				So(err.Error(), ShouldNotContainSubstring, "line 1")
+			})
+
+			Convey("should validate the records of draft-04 schema", func() {
+				err := validateJSONLinesWithSchemaFile("{\"id\":\"1\"}\n", "./fixtures/masterdata.schema.json", "./fixtures", "")
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "line 1: id: ")
+			})
+		})
+
+		Convey(".itemsSubschema", func() {
+			compile := func(schemaText string) *jsonschema.Schema {
+				compiler := newSchemaCompiler("", "")
+				So(compiler.AddResource("inline.schema.json", strings.NewReader(schemaText)), ShouldBeNil)
+				schema, err := compiler.Compile("inline.schema.json")
+				So(err, ShouldBeNil)
+				return schema
+			}
+
+			Convey("should return nil if the items are not constrained", func() {
+				items, err := itemsSubschema(compile(`{ "type": "array" }`))
+				So(err, ShouldBeNil)
+				So(items, ShouldBeNil)
+			})
+
+			Convey("with tuple items", func() {
+				Convey("should return error", func() {
+					_, err := itemsSubschema(compile(`{ "items": [{ "type": "integer" }] }`))
+					So(err, ShouldNotBeNil)
+				})
+			})
+
+			Convey("with items in combinators", func() {
+				Convey("should return error", func() {
+					for _, schemaText := range []string{
+						`{ "allOf": [{ "items": { "type": "integer" } }] }`,
+						`{ "oneOf": [{ "items": { "type": "integer" } }, { "items": { "type": "string" } }] }`,
+						`{ "items": { "type": "integer" }, "anyOf": [{ "minItems": 1 }] }`,
+					} {
+						_, err := itemsSubschema(compile(schemaText))
+						So(err, ShouldNotBeNil)
+					}
+				})
+			})
+
+			Convey("with constraints of the whole array", func() {
+				Convey("should return error", func() {
+					for _, schemaText := range []string{
+						`{ "items": { "type": "integer" }, "uniqueItems": true }`,
+						`{ "items": { "type": "integer" }, "minItems": 1 }`,
+						`{ "items": { "type": "integer" }, "maxItems": 10 }`,
+					} {
+						_, err := itemsSubschema(compile(schemaText))
+						So(err, ShouldNotBeNil)
+					}
+				})
+			})
+
+			Convey("with items in referenced schema", func() {
+				Convey("should return the items subschema", func() {
+					items, err := itemsSubschema(compile(
+						`{ "$ref": "#/definitions/records", "definitions": { "records": { "items": { "type": "integer" } } } }`))
+					So(err, ShouldBeNil)
+					So(items, ShouldNotBeNil)
+				})
+			})
+		})
 	})
 }
+
+// validateJSONWithSchemaText validates the JSON text by the JSON Schema text, which is written in a temporary file.
+func validateJSONWithSchemaText(jsonText string, schemaText string) error {
+	schemaDir, err := ioutil.TempDir("", "master")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(schemaDir)
+
+	schemaPath := filepath.Join(schemaDir, "test.schema.json")
+	if err := ioutil.WriteFile(schemaPath, []byte(schemaText), 0644); err != nil {
+		return err
+	}
+	return validateJSONWithSchemaFile(jsonText, schemaPath, schemaDir, "")
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// variantCSVPath returns the path of the CSV file which overlays the CSV file in the variant, e.g. "items.dev.csv".
+func variantCSVPath(filePath string, variant string) string {
+	return strings.TrimSuffix(filePath, ".csv") + "." + variant + ".csv"
+}
+
+// isVariantCSVPath reports whether the CSV file overlays another CSV file in the same directory in one of the variants.
+func isVariantCSVPath(filePath string, variants []string) bool {
+	name := strings.TrimSuffix(filepath.Base(filePath), ".csv")
+	separatorIndex := strings.LastIndex(name, ".")
+	if separatorIndex <= 0 {
+		return false
+	}
+	for _, variant := range variants {
+		if name[separatorIndex+1:] == variant {
+			_, err := os.Stat(filepath.Join(filepath.Dir(filePath), name[:separatorIndex]+".csv"))
+			return err == nil
+		}
+	}
+	return false
+}
+
+// overlayCSVRecords overlays the records of the variant CSV data on the enabled records, and returns them with their
+// line numbers. The rows are matched by the key column, and the empty cells of the variant rows keep the original
+// values. The variant rows and columns which are not in the records are added, but the variant rows of the disabled
+// rows are rejected, because they would bring the rows back without the original values.
+// The headers are matched by the column names without the kinds, e.g. "id" and "id:string".
+// The line numbers of the rows from the variant data have the variant file name.
+func overlayCSVRecords(records [][]string, variantData []byte, variantFileName string,
+	keyColumn string) ([][]string, []csvLine, error) {
+	variantRecords, err := csv.NewReader(bytes.NewReader(variantData)).ReadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+	keyIndex := 0
+	if keyColumn != "" {
+		keyIndex = csvHeaderIndex(records[0], keyColumn)
+	}
+	if keyIndex < 0 {
+		return nil, nil, fmt.Errorf("Key column is not found: %v", keyColumn)
+	}
+	disabledKeys := make(map[string]bool)
+	for _, record := range records[1:] {
+		if isDisabledCSVRecord(record) && keyIndex < len(record) {
+			disabledKeys[strings.TrimSpace(strings.TrimPrefix(record[keyIndex], "#"))] = true
+		}
+	}
+	records, lines := removeDisabledCSVRecords(records)
+	if len(variantRecords) == 0 {
+		return records, lines, nil
+	}
+	variantRecords, variantLines := removeDisabledCSVRecords(variantRecords)
+
+	variantKeyIndex := csvHeaderIndex(variantRecords[0], records[0][keyIndex])
+	if variantKeyIndex < 0 {
+		return nil, nil, fmt.Errorf("Variant data should have the key column: %v", records[0][keyIndex])
+	}
+
+	header := append([]string{}, records[0]...)
+	columnIndexes := make([]int, len(variantRecords[0]))
+	for i, variantHeader := range variantRecords[0] {
+		if columnIndexes[i] = csvHeaderIndex(header, variantHeader); columnIndexes[i] < 0 {
+			columnIndexes[i] = len(header)
+			header = append(header, variantHeader)
+		}
+	}
+
+	result := [][]string{header}
+	resultLines := append([]csvLine{}, lines...)
+	rowIndexes := make(map[string]int)
+	for _, record := range records[1:] {
+		row := make([]string, len(header))
+		copy(row, record)
+		if record[keyIndex] != "" {
+			rowIndexes[record[keyIndex]] = len(result)
+		}
+		result = append(result, row)
+	}
+
+	for i, variantRecord := range variantRecords[1:] {
+		key := variantRecord[variantKeyIndex]
+		if key == "" {
+			return nil, nil, fmt.Errorf("Key of variant data should not be empty in line %v of %v",
+				variantLines[i].number, variantFileName)
+		}
+
+		rowIndex, ok := rowIndexes[key]
+		if !ok && disabledKeys[key] {
+			return nil, nil, fmt.Errorf("Variant row in line %v of %v overlays the disabled row: %v",
+				variantLines[i].number, variantFileName, key)
+		} else if !ok {
+			rowIndex = len(result)
+			rowIndexes[key] = rowIndex
+			result = append(result, make([]string, len(header)))
+			resultLines = append(resultLines, csvLine{})
+		}
+		resultLines[rowIndex-1].variantNumber = variantLines[i].number
+		resultLines[rowIndex-1].variantFileName = variantFileName
+		for j, value := range variantRecord {
+			if value != "" {
+				result[rowIndex][columnIndexes[j]] = value
+			}
+		}
+	}
+	return result, resultLines, nil
+}
+
+// csvHeaderIndex returns the index of the header which has the column name, or -1 if it's not found.
+// The kinds of both are ignored, e.g. "id:string" is found by "id".
+func csvHeaderIndex(header []string, name string) int {
+	for i, value := range header {
+		if csvHeaderName(value) == csvHeaderName(name) {
+			return i
+		}
+	}
+	return -1
+}
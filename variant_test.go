@@ -0,0 +1,123 @@
+package main
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+func TestVariant(t *testing.T) {
+	Convey("variant", t, func() {
+		Convey(".variantCSVPath", func() {
+			Convey("should return the path of the variant file", func() {
+				So(variantCSVPath("data/items.csv", "dev"), ShouldEqual, "data/items.dev.csv")
+			})
+		})
+
+		Convey(".isVariantCSVPath", func() {
+			Convey("should return true only if the original file exists", func() {
+				So(isVariantCSVPath("fixtures/masterdata.csv", []string{"dev"}), ShouldBeFalse)
+				So(isVariantCSVPath("fixtures/masterdata.dev.csv", []string{"dev"}), ShouldBeTrue)
+				So(isVariantCSVPath("fixtures/masterdata-utf-8.csv", []string{"dev"}), ShouldBeFalse)
+				So(isVariantCSVPath("fixtures/items.dev.csv", []string{"dev"}), ShouldBeFalse)
+			})
+
+			Convey("should return false if the suffix is not a variant", func() {
+				So(isVariantCSVPath("fixtures/masterdata.dev.csv", []string{"prod"}), ShouldBeFalse)
+				So(isVariantCSVPath("fixtures/masterdata.dev.csv", nil), ShouldBeFalse)
+			})
+		})
+
+		Convey(".overlayCSVRecords", func() {
+			records := [][]string{
+				{"id", "name", "drop_rate"},
+				{"1", "Sword", "0.1"},
+				{"", "", "0.2"},
+				{"#4", "Bow", "0.4"},
+				{"2", "Shield", "0.3"},
+			}
+
+			Convey("should overlay the rows and the columns matched by the key column", func() {
+				variantData := []byte("id,drop_rate,debug\n2,1,TRUE\n3,1,\n# 4,1,\n1,,TRUE\n")
+				actual, actualLines, err := overlayCSVRecords(records, variantData, "items.dev.csv", "")
+				So(err, ShouldBeNil)
+				So(actual, ShouldResemble, [][]string{
+					{"id", "name", "drop_rate", "debug"},
+					{"1", "Sword", "0.1", "TRUE"},
+					{"", "", "0.2", ""},
+					{"2", "Shield", "1", "TRUE"},
+					{"3", "", "1", ""},
+				})
+				So(actualLines, ShouldResemble, []csvLine{
+					{number: 2, variantNumber: 5, variantFileName: "items.dev.csv"},
+					{number: 3},
+					{number: 5, variantNumber: 2, variantFileName: "items.dev.csv"},
+					{variantNumber: 3, variantFileName: "items.dev.csv"},
+				})
+			})
+
+			Convey("should use the key column", func() {
+				variantData := []byte("name,drop_rate\nShield,1\n")
+				actual, _, err := overlayCSVRecords(records, variantData, "items.dev.csv", "name")
+				So(err, ShouldBeNil)
+				So(actual[3], ShouldResemble, []string{"2", "Shield", "1"})
+			})
+
+			Convey("should match the headers without the kinds", func() {
+				records := [][]string{{"id:string", "drop_rate"}, {"1", "0.1"}}
+				actual, _, err := overlayCSVRecords(records, []byte("id,drop_rate:string\n1,1\n"), "items.dev.csv", "")
+				So(err, ShouldBeNil)
+				So(actual, ShouldResemble, [][]string{{"id:string", "drop_rate"}, {"1", "1"}})
+			})
+
+			Convey("with the key of a disabled row in the variant data", func() {
+				Convey("should return error", func() {
+					_, _, err := overlayCSVRecords(records, []byte("id,drop_rate\n4,1\n"), "items.dev.csv", "")
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldEqual, "Variant row in line 2 of items.dev.csv overlays the disabled row: 4")
+				})
+			})
+
+			Convey("without the key column in the variant data", func() {
+				Convey("should return error", func() {
+					_, _, err := overlayCSVRecords(records, []byte("name,drop_rate\nShield,1\n"), "items.dev.csv", "")
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldEqual, "Variant data should have the key column: id")
+				})
+			})
+
+			Convey("with empty key in the variant data", func() {
+				Convey("should return error", func() {
+					_, _, err := overlayCSVRecords(records, []byte("id,drop_rate\n,1\n"), "items.dev.csv", "")
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldEqual, "Key of variant data should not be empty in line 2 of items.dev.csv")
+				})
+			})
+
+			Convey("with invalid value in the variant data", func() {
+				Convey("should return error with the line number in the variant file", func() {
+					options := &CSVTableOptions{
+						location:        time.UTC,
+						variantData:     []byte("id,start_at:datetime\n1,2026-10-01\n3,x\n"),
+						variantFileName: "items.dev.csv",
+					}
+					_, err := newCSVTableWithOptions("items.csv", "utf-8", []byte("id,start_at:datetime\n1,\n"), options)
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldEqual, "Invalid datetime in line 3 of items.dev.csv, column start_at: x")
+				})
+
+				Convey("should return error with the overlaid line number", func() {
+					options := &CSVTableOptions{
+						location:        time.UTC,
+						variantData:     []byte("id,start_at:datetime\n1,x\n"),
+						variantFileName: "items.dev.csv",
+					}
+					_, err := newCSVTableWithOptions("items.csv", "utf-8", []byte("id,start_at:datetime\n1,\n"), options)
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldEqual,
+						"Invalid datetime in line 2 (overlaid by line 2 of items.dev.csv), column start_at: x")
+				})
+			})
+		})
+	})
+}